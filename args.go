@@ -0,0 +1,98 @@
+package pgtools
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// Args returns the values of the fields of v in the same order as InsertColumns,
+// so they can be used as the arguments of the statements built by Insert and UpdateSet:
+//
+//	_, err := conn.Exec(ctx, pgtools.Insert("user", u), pgtools.Args(u)...)
+//
+// Like InsertColumns, it follows the same order as Fields, but skips readonly columns.
+// Values of fields inside a nil pointer to a struct are nil.
+func Args(v interface{}) []interface{} {
+	rt := typeOf(v)
+	if rt == nil {
+		return nil
+	}
+	info := cachedTypeInfo(cacheKey{t: rt, tagKey: structref.DefaultTagKey})
+	rv := structValue(v)
+	args := make([]interface{}, 0, len(info.writable.fields))
+	for i := range info.writable.fields {
+		args = append(args, info.writable.value(rv, i))
+	}
+	return args
+}
+
+// NamedArgs returns the values of the fields of v keyed by their named parameter,
+// as used by WildcardNamed.
+// The result can be converted to pgx.NamedArgs when using pgx v5.
+//
+// The named parameter of a column is its name, with any dot of a nested struct column
+// replaced by a double underscore: the column "address.city" maps to the named parameter @address__city.
+//
+// Unlike Args, readonly columns are included, so you can reference them in a WHERE clause.
+// Values of fields inside a nil pointer to a struct are nil.
+func NamedArgs(v interface{}) map[string]interface{} {
+	rt := typeOf(v)
+	if rt == nil {
+		return nil
+	}
+	info := cachedTypeInfo(cacheKey{t: rt, tagKey: structref.DefaultTagKey})
+	rv := structValue(v)
+	args := make(map[string]interface{}, len(info.all.names))
+	for i, column := range info.all.names {
+		args[namedArg(column)] = info.all.value(rv, i)
+	}
+	return args
+}
+
+// namedArg returns the named parameter for a column, without the @ prefix.
+func namedArg(column string) string {
+	return strings.ReplaceAll(column, ".", "__")
+}
+
+// structValue returns the struct value of v, dereferencing pointers.
+// If v is a nil pointer, an invalid value is returned.
+func structValue(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// value returns the value written to the i-th column of the struct value rv,
+// converted by the function registered with RegisterScalarValue for its type, if any.
+func (cs *columnSet) value(rv reflect.Value, i int) interface{} {
+	v := fieldValue(rv, cs.fields[i].Index)
+	if fn := cs.values[i]; fn != nil && v != nil {
+		return fn(v)
+	}
+	return v
+}
+
+// fieldValue returns the value of the nested field of rv at index.
+// If a nil pointer to a struct is found on the way, or rv is invalid, nil is returned.
+func fieldValue(rv reflect.Value, index []int) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	for _, i := range index {
+		if rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return nil
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(i)
+	}
+	return rv.Interface()
+}
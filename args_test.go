@@ -0,0 +1,205 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleNamedArgs() {
+	u := User{
+		Username: "henvic",
+		FullName: "Henrique Vicente",
+		Email:    "henvic@example.com",
+		Alias:    "h",
+	}
+	sql := `INSERT INTO "user" (` + pgtools.InsertColumns(u) + `) VALUES (` + pgtools.WildcardNamed(u) + `)`
+	fmt.Println(sql)
+	fmt.Println(pgtools.NamedArgs(u)["full_name"])
+	// Output:
+	// INSERT INTO "user" ("username","full_name","email","id","theme") VALUES (@username, @full_name, @email, @id, @theme)
+	// Henrique Vicente
+}
+
+func ExampleArgs() {
+	u := User{
+		Username: "henvic",
+		FullName: "Henrique Vicente",
+		Email:    "henvic@example.com",
+		Alias:    "h",
+	}
+	fmt.Println(pgtools.Insert("user", u))
+	fmt.Println(pgtools.Args(u)[:4]...)
+	// Output:
+	// INSERT INTO "user" ("username","full_name","email","id","theme") VALUES ($1, $2, $3, $4, $5)
+	// henvic Henrique Vicente henvic@example.com h
+}
+
+type address struct {
+	Street string
+	City   string
+}
+
+type customer struct {
+	Name    string
+	Address *address
+	Ignored string `db:"-"`
+}
+
+func TestNamedArgs(t *testing.T) {
+	t.Parallel()
+	var uninitializedPointer *mock
+	testCases := []struct {
+		v     interface{}
+		desc  string
+		named string
+		want  map[string]interface{}
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+			want: map[string]interface{}{},
+		},
+		{
+			v: &mock{
+				Automatic: "auto",
+				Tagged:    "tag",
+				OneTwo:    "one two",
+				CamelCase: "camel",
+				Ignored:   "ignored",
+			},
+			desc:  "mock",
+			named: `@automatic, @tagged, @one_two, @CamelCase`,
+			want: map[string]interface{}{
+				"automatic": "auto",
+				"tagged":    "tag",
+				"one_two":   "one two",
+				"CamelCase": "camel",
+			},
+		},
+		{
+			v:     uninitializedPointer,
+			desc:  "uninitializedPointer",
+			named: `@automatic, @tagged, @one_two, @CamelCase`,
+			want: map[string]interface{}{
+				"automatic": nil,
+				"tagged":    nil,
+				"one_two":   nil,
+				"CamelCase": nil,
+			},
+		},
+		{
+			v:     mockEmbed{Before: 1, mock: mock{Automatic: "auto"}, After: "after"},
+			desc:  "embed",
+			named: `@before, @automatic, @tagged, @one_two, @CamelCase, @after`,
+			want: map[string]interface{}{
+				"before":    1,
+				"automatic": "auto",
+				"tagged":    "",
+				"one_two":   "",
+				"CamelCase": "",
+				"after":     "after",
+			},
+		},
+		{
+			v:     customer{Name: "Alice", Address: &address{Street: "Rua Augusta", City: "Lisbon"}},
+			desc:  "nested",
+			named: `@name, @address__street, @address__city, @address`,
+			want: map[string]interface{}{
+				"name":            "Alice",
+				"address__street": "Rua Augusta",
+				"address__city":   "Lisbon",
+				"address":         &address{Street: "Rua Augusta", City: "Lisbon"},
+			},
+		},
+		{
+			v:     customer{Name: "Bob"},
+			desc:  "nested nil",
+			named: `@name, @address__street, @address__city, @address`,
+			want: map[string]interface{}{
+				"name":            "Bob",
+				"address__street": nil,
+				"address__city":   nil,
+				"address":         (*address)(nil),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.NamedArgs(tc.v); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected args to be %v, got %v instead", tc.want, got)
+			}
+			if got := pgtools.WildcardNamed(tc.v); tc.named != got {
+				t.Errorf("expected named parameters to be %v, got %v instead", tc.named, got)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	t.Parallel()
+	var uninitializedPointer *mock
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want []interface{}
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+			want: []interface{}{},
+		},
+		{
+			v: &mock{
+				Automatic: "auto",
+				Tagged:    "tag",
+				OneTwo:    "one two",
+				CamelCase: "camel",
+				Ignored:   "ignored",
+			},
+			desc: "mock",
+			want: []interface{}{"auto", "tag", "one two", "camel"},
+		},
+		{
+			v:    uninitializedPointer,
+			desc: "uninitializedPointer",
+			want: []interface{}{nil, nil, nil, nil},
+		},
+		{
+			v:    mockMultiEmbed{A: "a", mock: mock{Tagged: "tag"}, B: "b", numericMock: numericMock{Number: 7}, C: "c"},
+			desc: "multiembed",
+			want: []interface{}{"a", "", "tag", "", "", "b", 7, "c"},
+		},
+		{
+			v:    customer{Name: "Alice", Address: &address{Street: "Rua Augusta", City: "Lisbon"}},
+			desc: "nested",
+			want: []interface{}{"Alice", "Rua Augusta", "Lisbon", &address{Street: "Rua Augusta", City: "Lisbon"}},
+		},
+		{
+			v:    customer{Name: "Bob"},
+			desc: "nested nil",
+			want: []interface{}{"Bob", nil, nil, (*address)(nil)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := pgtools.Args(tc.v)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected args to be %v, got %v instead", tc.want, got)
+			}
+			if tc.v != nil && len(got) != len(pgtools.Fields(tc.v)) {
+				t.Errorf("expected %d args, got %d instead", len(pgtools.Fields(tc.v)), len(got))
+			}
+		})
+	}
+}
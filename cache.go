@@ -0,0 +1,253 @@
+package pgtools
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// lru is the least recently used caching for the Fields function.
+//
+// Cache hits don't acquire the mutex: types are looked up first in hot, an immutable snapshot
+// of the cached types. Copying every cached type whenever one is added would make each miss
+// as expensive as the capacity, so the snapshot is only replaced once the number of changes since
+// it was published is a fraction of the cached types; until then, the new types are found under the mutex,
+// and the evicted ones are marked so the snapshot doesn't return them.
+// As hits served by the snapshot don't move entries to the front of the list, entries are marked as
+// referenced instead, and given a second chance when they would be evicted (CLOCK algorithm).
+type lru struct {
+	hits uint64       // Number of cache hits. First in the struct for 64-bit alignment, as it's accessed atomically.
+	hot  atomic.Value // map[cacheKey]*cacheEntry snapshot of m.
+
+	mu  sync.Mutex // guards following
+	cap int        // Capacity.
+	m   map[cacheKey]*list.Element
+	l   *list.List
+
+	stats CacheStatistics // Hits are counted by the hits field.
+	stale int             // Number of types added since hot was published.
+}
+
+// CacheStatistics of the Fields cache.
+type CacheStatistics struct {
+	Hits      uint64 // Hits is the number of lookups of a cached type.
+	Misses    uint64 // Misses is the number of lookups of a type that wasn't cached.
+	Evictions uint64 // Evictions is the number of types removed from the cache due to its capacity.
+	Len       int    // Len is the number of types currently cached.
+}
+
+// cacheKey identifies the columns of a struct type read using a given struct tag key.
+type cacheKey struct {
+	t         reflect.Type
+	tagKey    string
+	separator string // Separator of nested struct columns, or empty for the default.
+}
+
+// options to read the columns of the struct type of the key.
+func (k cacheKey) options() structref.Options {
+	return structref.Options{TagKey: k.tagKey, Separator: k.separator}
+}
+
+// cacheEntry exists to maintain a reference to the key in the linked list.
+type cacheEntry struct {
+	k cacheKey
+	v *typeInfo
+
+	referenced int32 // Set atomically when the entry is used, and cleared when it's given a second chance.
+	evicted    int32 // Set atomically when the entry is removed, so a snapshot still holding it skips it.
+}
+
+var wildcardsCache = &lru{
+	cap: 1000, // Likely high enough for most applications, but low enough to mitigate a memory leak.
+
+	m: map[cacheKey]*list.Element{},
+	l: list.New(),
+}
+
+// SetCacheCapacity sets how many struct types the Fields cache holds, 1000 by default.
+// If the cache holds more types than the new capacity, the least recently used ones are evicted.
+// A capacity of 0 disables caching, and columns are computed on every call.
+//
+// It is safe to call SetCacheCapacity concurrently with the other functions of this package.
+func SetCacheCapacity(n int) {
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+	wildcardsCache.cap = n
+	for wildcardsCache.l.Len() > 0 && wildcardsCache.l.Len() > n {
+		wildcardsCache.removeOldest()
+	}
+	wildcardsCache.publish()
+}
+
+// CacheStats returns the statistics of the Fields cache.
+// You can use it to check if the cache capacity fits your application.
+func CacheStats() CacheStatistics {
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+	stats := wildcardsCache.stats
+	stats.Hits = atomic.LoadUint64(&wildcardsCache.hits)
+	stats.Len = wildcardsCache.l.Len()
+	return stats
+}
+
+// ResetCache removes all struct types from the Fields cache, and resets its statistics.
+//
+// It is rarely needed in production, but you might want to use it to measure
+// the performance of the uncached path in tests and benchmarks.
+func ResetCache() {
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+	wildcardsCache.clear()
+	wildcardsCache.stats = CacheStatistics{}
+	atomic.StoreUint64(&wildcardsCache.hits, 0)
+}
+
+// InvalidateType removes the type of v from the Fields cache, regardless of the struct tag key used.
+//
+// Like ResetCache, it is rarely needed in production.
+func InvalidateType(v interface{}) {
+	rv := typeOf(v)
+	if rv == nil {
+		return
+	}
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+	for k, e := range wildcardsCache.m {
+		if k.t == rv {
+			atomic.StoreInt32(&e.Value.(*cacheEntry).evicted, 1)
+			wildcardsCache.l.Remove(e)
+			delete(wildcardsCache.m, k)
+		}
+	}
+	wildcardsCache.publish()
+}
+
+// Warm adds the struct types of the given values to the Fields cache, so the first query using them
+// doesn't pay the cost of reading their columns.
+// It is typically called during startup with all your models, as in:
+//
+//	pgtools.Warm(User{}, Team{}, Post{})
+//
+// Warmed types count toward the cache capacity, so warming more types than the capacity
+// evicts the ones warmed first. Values that aren't structs or pointers to structs are ignored.
+//
+// It is safe to call Warm concurrently with the other functions of this package.
+func Warm(vs ...interface{}) {
+	for _, v := range vs {
+		if rv := typeOf(v); rv != nil && rv.Kind() == reflect.Struct {
+			cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey})
+		}
+	}
+}
+
+// cachedFields returns the columns for the struct type and tag key using the LRU cache.
+func cachedFields(key cacheKey) []string {
+	return cachedTypeInfo(key).all.names
+}
+
+// writableFields returns the columns of v that can be written by INSERT and UPDATE statements.
+func writableFields(v interface{}) []string {
+	rv := typeOf(v)
+	if rv == nil {
+		return nil
+	}
+	return cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).writable.names
+}
+
+// cachedTypeInfo returns what is known about the struct type and tag key using the LRU cache.
+func cachedTypeInfo(key cacheKey) *typeInfo {
+	// Fast path for types already cached.
+	if hot, _ := wildcardsCache.hot.Load().(map[cacheKey]*cacheEntry); hot != nil {
+		if e, ok := hot[key]; ok && atomic.LoadInt32(&e.evicted) == 0 {
+			atomic.AddUint64(&wildcardsCache.hits, 1)
+			// Avoid writing to memory shared by all goroutines using the type if possible.
+			if atomic.LoadInt32(&e.referenced) == 0 {
+				atomic.StoreInt32(&e.referenced, 1)
+			}
+			return e.v
+		}
+	}
+
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+
+	if wildcardsCache.cap <= 0 {
+		// Caching is disabled.
+		wildcardsCache.stats.Misses++
+		return newTypeInfo(key.t, key.options())
+	}
+
+	// Keep the map and linked list of the LRU cache up-to-date.
+	// The type might have been cached since the fast path was tried.
+	if cache, ok := wildcardsCache.m[key]; ok {
+		atomic.AddUint64(&wildcardsCache.hits, 1)
+		wildcardsCache.l.MoveToFront(cache)
+		return cache.Value.(*cacheEntry).v
+	}
+	wildcardsCache.stats.Misses++
+
+	// If we don't have the data cached yet, continue.
+	for wildcardsCache.l.Len() >= wildcardsCache.cap {
+		wildcardsCache.removeOldest()
+	}
+
+	// Get the columns, cache, and return it.
+	info := newTypeInfo(key.t, key.options())
+	wildcardsCache.m[key] = wildcardsCache.l.PushFront(&cacheEntry{
+		k: key,
+		v: info,
+	})
+	wildcardsCache.added()
+	return info
+}
+
+// clear removes all entries, keeping the statistics.
+// The caller must hold c.mu.
+func (c *lru) clear() {
+	c.m = map[cacheKey]*list.Element{}
+	c.l.Init()
+	c.publish()
+}
+
+// removeOldest evicts the least recently used entry,
+// skipping the entries referenced since they were last considered for eviction.
+// The caller must hold c.mu.
+func (c *lru) removeOldest() {
+	for {
+		oldest := c.l.Back()
+		e := oldest.Value.(*cacheEntry)
+		if atomic.SwapInt32(&e.referenced, 0) == 1 && c.l.Len() > 1 {
+			c.l.MoveToFront(oldest)
+			continue
+		}
+		atomic.StoreInt32(&e.evicted, 1)
+		c.l.Remove(oldest)
+		delete(c.m, e.k)
+		c.stats.Evictions++
+		return
+	}
+}
+
+// added records a type added to the cache, and publishes a new snapshot once a quarter of the cached types
+// were added since the last one, so the cost of copying them is amortized over the misses.
+// The caller must hold c.mu.
+func (c *lru) added() {
+	c.stale++
+	if c.stale > len(c.m)/4 {
+		c.publish()
+	}
+}
+
+// publish a snapshot of the cached types for lookups without locking.
+// The caller must hold c.mu.
+func (c *lru) publish() {
+	hot := make(map[cacheKey]*cacheEntry, len(c.m))
+	for k, e := range c.m {
+		hot[k] = e.Value.(*cacheEntry)
+	}
+	c.hot.Store(hot)
+	c.stale = 0
+}
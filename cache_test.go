@@ -2,8 +2,13 @@ package pgtools
 
 import (
 	"container/list"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/partounian/pgtools/internal/structref"
 )
 
 func TestWildcardCache(t *testing.T) {
@@ -16,7 +21,7 @@ func TestWildcardCache(t *testing.T) {
 	wildcardsCache = &lru{
 		cap: maxCached,
 
-		m: map[reflect.Type]*list.Element{},
+		m: map[cacheKey]*list.Element{},
 		l: list.New(),
 	}
 
@@ -107,3 +112,273 @@ func TestWildcardCache(t *testing.T) {
 		}
 	}
 }
+
+func TestWildcardCacheTagKey(t *testing.T) {
+	old := wildcardsCache
+	t.Cleanup(func() {
+		wildcardsCache = old // Restore default caching.
+	})
+	wildcardsCache = &lru{
+		cap: 3,
+
+		m: map[cacheKey]*list.Element{},
+		l: list.New(),
+	}
+
+	type legacy struct {
+		ID   string `db:"id" sql:"legacy_id"`
+		Name string `sql:"legacy_name"`
+	}
+	if want, got := `"id","name"`, Wildcard(legacy{}); want != got {
+		t.Errorf("wanted %v, got %v instead", want, got)
+	}
+	if want, got := `"legacy_id","legacy_name"`, WildcardWithTag(legacy{}, "sql"); want != got {
+		t.Errorf("wanted %v, got %v instead", want, got)
+	}
+	if want, got := `"id","name"`, Wildcard(legacy{}); want != got {
+		t.Errorf("wanted cached value %v, got %v instead", want, got)
+	}
+	if len(wildcardsCache.m) != 2 {
+		t.Errorf("wanted %d cached items, found %d", 2, len(wildcardsCache.m))
+	}
+}
+
+func TestSetCacheCapacity(t *testing.T) {
+	old := wildcardsCache
+	t.Cleanup(func() {
+		wildcardsCache = old // Restore default caching.
+	})
+	wildcardsCache = &lru{
+		cap: 1000,
+
+		m: map[cacheKey]*list.Element{},
+		l: list.New(),
+	}
+
+	Wildcard(struct{ A string }{})
+	Wildcard(struct{ B string }{})
+	Wildcard(struct{ C string }{})
+	Wildcard(struct{ A string }{}) // Most recently used.
+
+	SetCacheCapacity(2)
+	if len(wildcardsCache.m) != 2 || wildcardsCache.l.Len() != 2 {
+		t.Errorf("wanted %d cached items, found %d", 2, len(wildcardsCache.m))
+	}
+	if _, ok := wildcardsCache.m[cacheKey{t: reflect.TypeOf(struct{ A string }{}), tagKey: "db"}]; !ok {
+		t.Error("most recently used item should not be evicted")
+	}
+	if _, ok := wildcardsCache.m[cacheKey{t: reflect.TypeOf(struct{ B string }{}), tagKey: "db"}]; ok {
+		t.Error("least recently used item should be evicted")
+	}
+
+	SetCacheCapacity(0)
+	if len(wildcardsCache.m) != 0 || wildcardsCache.l.Len() != 0 {
+		t.Errorf("wanted cache to be empty, found %d items", len(wildcardsCache.m))
+	}
+	if want, got := `"d"`, Wildcard(struct{ D string }{}); want != got {
+		t.Errorf("wanted %v, got %v instead", want, got)
+	}
+	if len(wildcardsCache.m) != 0 || wildcardsCache.l.Len() != 0 {
+		t.Errorf("caching should be disabled, found %d items", len(wildcardsCache.m))
+	}
+
+	SetCacheCapacity(1)
+	Wildcard(struct{ D string }{})
+	Wildcard(struct{ E string }{})
+	if len(wildcardsCache.m) != 1 || wildcardsCache.l.Len() != 1 {
+		t.Errorf("wanted %d cached items, found %d", 1, len(wildcardsCache.m))
+	}
+}
+
+func TestResetCache(t *testing.T) {
+	old := wildcardsCache
+	t.Cleanup(func() {
+		wildcardsCache = old // Restore default caching.
+	})
+	wildcardsCache = &lru{
+		cap: 1000,
+
+		m: map[cacheKey]*list.Element{},
+		l: list.New(),
+	}
+
+	type a struct{ A string }
+	type b struct{ B string }
+	Wildcard(a{})
+	WildcardWithTag(&a{}, "sql")
+	Wildcard(b{})
+
+	InvalidateType(nil)
+	InvalidateType(&a{})
+	if len(wildcardsCache.m) != 1 || wildcardsCache.l.Len() != 1 {
+		t.Errorf("wanted %d cached items, found %d", 1, len(wildcardsCache.m))
+	}
+	if _, ok := wildcardsCache.m[cacheKey{t: reflect.TypeOf(b{}), tagKey: "db"}]; !ok {
+		t.Error("other types should not be invalidated")
+	}
+
+	ResetCache()
+	if len(wildcardsCache.m) != 0 || wildcardsCache.l.Len() != 0 {
+		t.Errorf("wanted cache to be empty, found %d items", len(wildcardsCache.m))
+	}
+	if want, got := `"a"`, Wildcard(a{}); want != got {
+		t.Errorf("wanted %v, got %v instead", want, got)
+	}
+	if len(wildcardsCache.m) != 1 || wildcardsCache.l.Len() != 1 {
+		t.Errorf("wanted %d cached items, found %d", 1, len(wildcardsCache.m))
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	old := wildcardsCache
+	t.Cleanup(func() {
+		wildcardsCache = old // Restore default caching.
+	})
+	wildcardsCache = &lru{
+		cap: 2,
+
+		m: map[cacheKey]*list.Element{},
+		l: list.New(),
+	}
+
+	Wildcard(struct{ A string }{})
+	Wildcard(struct{ A string }{})
+	Wildcard(struct{ B string }{})
+	Wildcard(struct{ C string }{})
+	Wildcard(struct{ C string }{})
+	Wildcard(struct{ C string }{})
+
+	want := CacheStatistics{
+		Hits:      3,
+		Misses:    3,
+		Evictions: 1,
+		Len:       2,
+	}
+	if got := CacheStats(); got != want {
+		t.Errorf("wanted %+v, got %+v instead", want, got)
+	}
+
+	ResetCache()
+	if got := CacheStats(); got != (CacheStatistics{}) {
+		t.Errorf("wanted empty statistics, got %+v instead", got)
+	}
+}
+
+func TestFieldsConcurrent(t *testing.T) {
+	// Create a new type, so it isn't cached yet.
+	rt := reflect.StructOf([]reflect.StructField{
+		{Name: "ID", Type: reflect.TypeOf(""), Tag: `db:"id"`},
+		{Name: "FullName", Type: reflect.TypeOf("")},
+		{Name: "CreatedAt", Type: reflect.TypeOf(time.Time{}), Tag: `db:"created_at,readonly"`},
+		{Name: "Count", Type: reflect.TypeOf(0)},
+	})
+	v := reflect.New(rt).Interface()
+	InvalidateType(v)
+
+	const goroutines = 50
+	want := []string{"id", "full_name", "created_at", "count"}
+	var wg sync.WaitGroup
+	results := make([][]string, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = Fields(v)
+			InsertColumns(v)
+			Wildcard(v)
+		}(i)
+	}
+	wg.Wait()
+	for i, got := range results {
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("expected fields of goroutine %d to be %v, got %v instead", i, want, got)
+		}
+	}
+	if stats := CacheStats(); stats.Len == 0 {
+		t.Errorf("expected type to be cached, got %+v instead", stats)
+	}
+}
+
+func TestFieldsCopy(t *testing.T) {
+	type model struct {
+		ID   string
+		Name string
+	}
+	got := Fields(model{})
+	got[0] = "modified"
+	FieldsOf[model]()[1] = "modified"
+	FieldsWithTag(model{}, "db")[0] = "modified"
+	if want, got := []string{"id", "name"}, Fields(model{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields to be %v, got %v instead", want, got)
+	}
+	if want, got := `"id","name"`, Wildcard(model{}); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	old := wildcardsCache
+	t.Cleanup(func() {
+		wildcardsCache = old // Restore default caching.
+	})
+	wildcardsCache = &lru{
+		cap: 2,
+
+		m: map[cacheKey]*list.Element{},
+		l: list.New(),
+	}
+
+	type a struct{ A string }
+	type b struct{ B string }
+	type c struct{ C string }
+	Warm(nil, "not a struct", a{}, &b{})
+	want := CacheStatistics{
+		Misses: 2,
+		Len:    2,
+	}
+	if got := CacheStats(); got != want {
+		t.Errorf("wanted %+v, got %+v instead", want, got)
+	}
+	Wildcard(a{})
+	if got := CacheStats().Hits; got != 1 {
+		t.Errorf("wanted warmed type to be a cache hit, got %d hits instead", got)
+	}
+
+	// Warming more types than the capacity evicts the ones warmed first.
+	Warm(c{})
+	if _, ok := wildcardsCache.m[cacheKey{t: reflect.TypeOf(c{}), tagKey: "db"}]; !ok {
+		t.Error("warmed type should be cached")
+	}
+	if len(wildcardsCache.m) != 2 {
+		t.Errorf("wanted %d cached items, found %d", 2, len(wildcardsCache.m))
+	}
+}
+
+func BenchmarkCacheThrashing(b *testing.B) {
+	// A working set larger than the capacity misses on every lookup.
+	for _, capacity := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("cap%d", capacity), func(b *testing.B) {
+			old := wildcardsCache
+			b.Cleanup(func() {
+				wildcardsCache = old // Restore default caching.
+			})
+			wildcardsCache = &lru{
+				cap: capacity,
+
+				m: map[cacheKey]*list.Element{},
+				l: list.New(),
+			}
+			types := make([]reflect.Type, 2*capacity)
+			for i := range types {
+				types[i] = reflect.StructOf([]reflect.StructField{{
+					Name: fmt.Sprintf("F%d", i),
+					Type: reflect.TypeOf(""),
+				}})
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cachedTypeInfo(cacheKey{t: types[i%len(types)], tagKey: structref.DefaultTagKey})
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package pgtools
+
+// CopyColumns returns the column names to pass to pgx's CopyFrom for v, unquoted,
+// as pgx quotes them itself.
+//
+// It is the canonical order of the values returned by CopyRow, so a bulk load doesn't break
+// when fields are added to or reordered in the struct:
+//
+//	_, err := conn.CopyFrom(ctx, pgx.Identifier{"users"}, pgtools.CopyColumns(User{}),
+//		pgx.CopyFromSlice(len(users), func(i int) ([]interface{}, error) {
+//			return pgtools.CopyRow(users[i]), nil
+//		}))
+//
+// Like InsertColumns, columns are listed in the same order as Fields, except that readonly columns are omitted.
+// The returned slice is a copy, so it's safe to modify it.
+func CopyColumns(v interface{}) []string {
+	return copyColumns(writableFields(v))
+}
+
+// CopyRow returns the values of the fields of v in the same order as CopyColumns.
+// It returns the same values as Args.
+func CopyRow(v interface{}) []interface{} {
+	return Args(v)
+}
@@ -0,0 +1,56 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleCopyColumns() {
+	type user struct {
+		ID       int    `db:"id,readonly"`
+		Username string `db:"username"`
+		Email    string `db:"email"`
+	}
+	u := user{ID: 1, Username: "alice", Email: "alice@example.com"}
+	fmt.Println(pgtools.CopyColumns(u))
+	fmt.Println(pgtools.CopyRow(u))
+	// Output:
+	// [username email]
+	// [alice alice@example.com]
+}
+
+func TestCopyColumns(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City string
+	}
+	type order struct {
+		ID       int `db:"id,readonly"`
+		Product  string
+		Quantity int
+		Address  address `db:"shipping"`
+	}
+	o := order{ID: 7, Product: "book", Quantity: 2, Address: address{City: "Lisbon"}}
+
+	wantColumns := []string{"product", "quantity", "shipping.city", "shipping"}
+	columns := pgtools.CopyColumns(&o)
+	if !reflect.DeepEqual(wantColumns, columns) {
+		t.Errorf("expected columns to be %q, got %q instead", wantColumns, columns)
+	}
+	wantRow := []interface{}{"book", 2, "Lisbon", address{City: "Lisbon"}}
+	if row := pgtools.CopyRow(&o); !reflect.DeepEqual(wantRow, row) {
+		t.Errorf("expected row to be %v, got %v instead", wantRow, row)
+	}
+
+	// Check modifying the returned slice doesn't change the cached columns.
+	columns[0] = "changed"
+	if got := pgtools.CopyColumns(o); !reflect.DeepEqual(wantColumns, got) {
+		t.Errorf("expected columns to be %q after modification, got %q instead", wantColumns, got)
+	}
+	if got := pgtools.CopyColumns(nil); got != nil {
+		t.Errorf("expected columns of nil to be nil, got %q instead", got)
+	}
+}
@@ -0,0 +1,70 @@
+package pgtools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// Querier runs a query, and is implemented by *pgx.Conn, *pgxpool.Pool, and pgx.Tx.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// DiffColumns compares the columns of a table with the columns returned by Fields for v,
+// so you can write a test that fails when they drift apart:
+//
+//	missingInDB, missingInStruct, err := pgtools.DiffColumns(ctx, conn, "user", User{})
+//
+// missingInDB lists the columns of v the table doesn't have, in the same order as Fields,
+// and missingInStruct lists the columns of the table v doesn't have, in the order of the table.
+// Columns of nested structs, such as address.street, usually come from a JOIN instead of the table,
+// so they're reported as missing in the database unless the table has a column with the same name.
+//
+// The table is looked up in information_schema.columns by its exact name in the current schema,
+// which is the first schema of the search_path, unless it's qualified with a schema, as in "analytics.events".
+// An error is returned if the table has no columns, such as when it doesn't exist.
+func DiffColumns(ctx context.Context, conn Querier, table string, v interface{}) (missingInDB, missingInStruct []string, err error) {
+	var schema string
+	name := table
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		schema, name = table[:i], table[i+1:]
+	}
+	rows, err := conn.Query(ctx, `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		ORDER BY ordinal_position`, schema, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pgtools: cannot get columns of table %q: %w", table, err)
+	}
+	defer rows.Close()
+	var tableColumns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, nil, fmt.Errorf("pgtools: cannot get columns of table %q: %w", table, err)
+		}
+		tableColumns = append(tableColumns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("pgtools: cannot get columns of table %q: %w", table, err)
+	}
+	if len(tableColumns) == 0 {
+		return nil, nil, fmt.Errorf("pgtools: table %q not found", table)
+	}
+
+	columns := fields(v, structref.DefaultTagKey)
+	for _, c := range columns {
+		if !contains(tableColumns, c) {
+			missingInDB = append(missingInDB, c)
+		}
+	}
+	for _, c := range tableColumns {
+		if !contains(columns, c) {
+			missingInStruct = append(missingInStruct, c)
+		}
+	}
+	return missingInDB, missingInStruct, nil
+}
@@ -0,0 +1,126 @@
+package pgtools_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/partounian/pgtools"
+)
+
+// columnsQuerier returns the columns of a table as the result of a query.
+type columnsQuerier struct {
+	columns []string
+	err     error
+
+	args []interface{}
+}
+
+func (q *columnsQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	q.args = args
+	if q.err != nil {
+		return nil, q.err
+	}
+	return &columnRows{columns: q.columns, i: -1}, nil
+}
+
+// columnRows is the result of a query with a single text column.
+type columnRows struct {
+	columns []string
+	i       int
+}
+
+var _ pgx.Rows = (*columnRows)(nil)
+
+func (r *columnRows) Close()                        {}
+func (r *columnRows) Err() error                    { return nil }
+func (r *columnRows) CommandTag() pgconn.CommandTag { return nil }
+func (r *columnRows) Next() bool {
+	r.i++
+	return r.i < len(r.columns)
+}
+func (r *columnRows) Values() ([]interface{}, error) {
+	return []interface{}{r.columns[r.i]}, nil
+}
+func (r *columnRows) RawValues() [][]byte { return nil }
+func (r *columnRows) FieldDescriptions() []pgproto3.FieldDescription {
+	return []pgproto3.FieldDescription{{Name: []byte("column_name")}}
+}
+func (r *columnRows) Scan(dest ...interface{}) error {
+	*dest[0].(*string) = r.columns[r.i]
+	return nil
+}
+
+func TestDiffColumns(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc            string
+		querier         *columnsQuerier
+		schema          string
+		v               interface{}
+		missingInDB     []string
+		missingInStruct []string
+		wantErr         string
+	}{
+		{
+			desc:    "same",
+			querier: &columnsQuerier{columns: []string{"id", "username", "full_name", "email", "theme"}},
+			v:       User{},
+		},
+		{
+			desc:            "drift",
+			querier:         &columnsQuerier{columns: []string{"id", "username", "email", "created_at", "theme"}},
+			v:               &User{},
+			missingInDB:     []string{"full_name"},
+			missingInStruct: []string{"created_at"},
+		},
+		{
+			desc:    "schema",
+			querier: &columnsQuerier{columns: []string{"id", "username", "full_name", "email", "theme"}},
+			schema:  "auth",
+			v:       User{},
+		},
+		{
+			desc:    "not found",
+			querier: &columnsQuerier{},
+			v:       User{},
+			wantErr: `pgtools: table "user" not found`,
+		},
+		{
+			desc:    "error",
+			querier: &columnsQuerier{err: errors.New("connection refused")},
+			v:       User{},
+			wantErr: `pgtools: cannot get columns of table "user": connection refused`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			table := "user"
+			if tc.schema != "" {
+				table = tc.schema + ".user"
+			}
+			missingInDB, missingInStruct, err := pgtools.DiffColumns(context.Background(), tc.querier, table, tc.v)
+			if err != nil || tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("expected error to be %v, got %v instead", tc.wantErr, err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(missingInDB, tc.missingInDB) {
+				t.Errorf("expected columns missing in the database to be %v, got %v instead", tc.missingInDB, missingInDB)
+			}
+			if !reflect.DeepEqual(missingInStruct, tc.missingInStruct) {
+				t.Errorf("expected columns missing in the struct to be %v, got %v instead", tc.missingInStruct, missingInStruct)
+			}
+			if want := []interface{}{tc.schema, "user"}; !reflect.DeepEqual(tc.querier.args, want) {
+				t.Errorf("expected query arguments to be %v, got %v instead", want, tc.querier.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package pgtools
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// InsertColumns returns the quoted column list for an INSERT statement,
+// such as "username","full_name","email".
+//
+// Columns are listed in the same order as Fields, and follow the same "db" tag rules,
+// except that readonly columns are omitted.
+// Like Wildcard, an empty string is returned when no columns can be found.
+func InsertColumns(v interface{}) string {
+	return quoteColumns(writableFields(v))
+}
+
+// InsertValues returns the placeholder list for an INSERT statement, such as $1, $2, $3.
+//
+// The number of placeholders matches the number of columns returned by InsertColumns
+// for the same value.
+func InsertValues(v interface{}) string {
+	return placeholders(1, len(writableFields(v)))
+}
+
+// InsertValuesN returns the placeholder groups for a multi-row INSERT statement.
+// For a struct with two columns and rows = 3, it returns:
+//
+//	($1,$2),($3,$4),($5,$6)
+//
+// Use it with InsertColumns for bulk inserts.
+// If there are no columns or rows, an empty string is returned.
+func InsertValuesN(v interface{}, rows int) string {
+	return valuesRows(len(writableFields(v)), rows)
+}
+
+// valuesRows returns rows groups of n placeholders with sequential numbering.
+func valuesRows(n, rows int) string {
+	if n == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for r := 0; r < rows; r++ {
+		if r != 0 {
+			b.WriteString(`,`)
+		}
+		b.WriteString(`(`)
+		for i := 0; i < n; i++ {
+			if i != 0 {
+				b.WriteString(`,`)
+			}
+			b.WriteString(`$`)
+			b.WriteString(strconv.Itoa(r*n + i + 1))
+		}
+		b.WriteString(`)`)
+	}
+	return b.String()
+}
+
+// WildcardNamed returns the named parameters list for an INSERT statement, such as @username, @full_name, @email.
+// Use it with InsertColumns and the values returned by NamedArgs.
+func WildcardNamed(v interface{}) string {
+	var b strings.Builder
+	for n, s := range writableFields(v) {
+		if n != 0 {
+			b.WriteString(`, `)
+		}
+		b.WriteString(`@`)
+		b.WriteString(namedArg(s))
+	}
+	return b.String()
+}
+
+// Insert returns an INSERT statement for the given table, such as:
+//
+//	INSERT INTO "user" ("username","full_name","email") VALUES ($1, $2, $3)
+//
+// The table can be qualified with a schema, as in "analytics.events", which is quoted as "analytics"."events".
+// If table is empty, the table name of v is used if it implements Tabler.
+//
+// See InsertColumns and InsertValues.
+func Insert(table string, v interface{}) string {
+	if table == "" {
+		table = TableOf(v)
+	}
+	columns := writableFields(v)
+	return `INSERT INTO ` + quoteTable(table) + ` (` + quoteColumns(columns) + `) VALUES (` + placeholders(1, len(columns)) + `)`
+}
+
+// maxParams is the maximum number of parameters of a PostgreSQL statement.
+const maxParams = 65535
+
+// BulkInsert returns a multi-row INSERT statement for the given table and its arguments,
+// inserting every element of vs, which must be a slice of structs or of pointers to structs:
+//
+//	sql, args, err := pgtools.BulkInsert("user", users)
+//	// INSERT INTO "user" ("username","email") VALUES ($1,$2),($3,$4)
+//	_, err = conn.Exec(ctx, sql, args...)
+//
+// Columns are the same as Insert for the element type, and the arguments are the values
+// returned by Args for each element, in order.
+// If table is empty, the table name of the element type is used if it implements Tabler.
+//
+// An error is returned if vs is empty, if its elements have no columns, if table is empty and they don't
+// implement Tabler, or if the statement would exceed the 65535 parameters supported by PostgreSQL;
+// split vs into batches in that case.
+func BulkInsert(table string, vs interface{}) (sql string, args []interface{}, err error) {
+	rv := reflect.ValueOf(vs)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", nil, fmt.Errorf("pgtools: cannot bulk insert %T: not a slice", vs)
+	}
+	if rv.Len() == 0 {
+		return "", nil, errors.New("pgtools: cannot bulk insert an empty slice")
+	}
+	et := rv.Type().Elem()
+	for et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("pgtools: cannot bulk insert %T: elements aren't structs", vs)
+	}
+	info := cachedTypeInfo(cacheKey{t: et, tagKey: structref.DefaultTagKey})
+	columns := info.writable.names
+	if len(columns) == 0 {
+		return "", nil, fmt.Errorf("pgtools: %v has no columns", et)
+	}
+	if n := len(columns) * rv.Len(); n > maxParams {
+		return "", nil, fmt.Errorf("pgtools: cannot bulk insert %d rows: %d parameters exceed the limit of %d", rv.Len(), n, maxParams)
+	}
+	if table == "" {
+		if table = TableOf(reflect.New(et).Interface()); table == "" {
+			return "", nil, fmt.Errorf("pgtools: cannot bulk insert %T: no table name, and %v doesn't implement Tabler", vs, et)
+		}
+	}
+
+	args = make([]interface{}, 0, len(columns)*rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		ev := rv.Index(i)
+		for ev.Kind() == reflect.Ptr && !ev.IsNil() {
+			ev = ev.Elem()
+		}
+		if ev.Kind() == reflect.Ptr {
+			// Values of fields inside a nil pointer are nil, as with Args.
+			ev = reflect.Value{}
+		}
+		for j := range info.writable.fields {
+			args = append(args, info.writable.value(ev, j))
+		}
+	}
+	sql = `INSERT INTO ` + quoteTable(table) + ` (` + quoteColumns(columns) + `) VALUES ` + valuesRows(len(columns), rv.Len())
+	return sql, args, nil
+}
+
+// InsertColumnsMap returns the quoted column list, the placeholder list, and the arguments
+// for an INSERT statement writing the columns of a map, such as when you don't have a struct:
+//
+//	cols, values, args := pgtools.InsertColumnsMap(map[string]interface{}{"name": "Alice", "email": "alice@example.com"})
+//	_, err := conn.Exec(ctx, `INSERT INTO "user" (`+cols+`) VALUES (`+values+`)`, args...)
+//
+// Columns are sorted, so the output is deterministic, and quoted like InsertColumns:
+//
+//	"email","name"	$1, $2	[alice@example.com Alice]
+//
+// If the map is empty, empty strings and a nil slice are returned.
+func InsertColumnsMap(m map[string]interface{}) (cols, values string, args []interface{}) {
+	if len(m) == 0 {
+		return "", "", nil
+	}
+	columns := make([]string, 0, len(m))
+	for c := range m {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+	args = make([]interface{}, len(columns))
+	for i, c := range columns {
+		args[i] = m[c]
+	}
+	return quoteColumns(columns), placeholders(1, len(columns)), args
+}
+
+// quoteTable quotes a table name, quoting each part of a schema-qualified name separately,
+// so "analytics.events" is quoted as "analytics"."events".
+func quoteTable(table string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(table, `"`, `""`), `.`, `"."`) + `"`
+}
+
+// quoteColumns quotes each column and joins them with a comma, without aliasing.
+func quoteColumns(columns []string) string {
+	var b strings.Builder
+	for n, s := range columns {
+		if n != 0 {
+			b.WriteString(`,`)
+		}
+		writeIdentifier(&b, s)
+	}
+	return b.String()
+}
+
+// writeIdentifier writes the quoted identifier, such as a column name, to b.
+// Double quotes inside the identifier are escaped as "", so it cannot end the quoted identifier early.
+func writeIdentifier(b *strings.Builder, s string) {
+	b.WriteString(`"`)
+	if strings.Contains(s, `"`) {
+		s = strings.ReplaceAll(s, `"`, `""`)
+	}
+	b.WriteString(s)
+	b.WriteString(`"`)
+}
+
+// placeholders returns n positional parameters separated by a comma, starting from $start.
+func placeholders(start, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			b.WriteString(`, `)
+		}
+		b.WriteString(`$`)
+		b.WriteString(strconv.Itoa(start + i))
+	}
+	return b.String()
+}
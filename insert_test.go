@@ -0,0 +1,402 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleInsert() {
+	sql := pgtools.Insert("user", User{})
+	fmt.Println(sql)
+	// Output:
+	// INSERT INTO "user" ("username","full_name","email","id","theme") VALUES ($1, $2, $3, $4, $5)
+}
+
+func ExampleReturning() {
+	type created struct {
+		ID        string
+		CreatedAt time.Time
+	}
+	sql := `INSERT INTO "user" (` + pgtools.InsertColumns(User{}) + `) VALUES (` + pgtools.InsertValues(User{}) + `) ` + pgtools.Returning(created{})
+	fmt.Println(sql)
+	// Output:
+	// INSERT INTO "user" ("username","full_name","email","id","theme") VALUES ($1, $2, $3, $4, $5) RETURNING "id","created_at"
+}
+
+func TestReturning(t *testing.T) {
+	t.Parallel()
+	if got := pgtools.Returning(emptyEmbed{}); got != "" {
+		t.Errorf("expected empty clause, got %v instead", got)
+	}
+	if got := pgtools.Returning(nil); got != "" {
+		t.Errorf("expected empty clause, got %v instead", got)
+	}
+	if want, got := `RETURNING "id","name","code","is_active","theme","created_at","modified_at"`, pgtools.Returning(&jsonMock{}); want != got {
+		t.Errorf("expected clause to be %v, got %v instead", want, got)
+	}
+	if got := pgtools.ReturningFields(); got != "" {
+		t.Errorf("expected empty clause, got %v instead", got)
+	}
+	if want, got := `RETURNING "id","theme.text_color" as "theme.text_color"`, pgtools.ReturningFields("id", "theme.text_color"); want != got {
+		t.Errorf("expected clause to be %v, got %v instead", want, got)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v       interface{}
+		desc    string
+		columns string
+		values  string
+	}{
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+		},
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v: struct {
+				One int
+			}{},
+			desc:    "single",
+			columns: `"one"`,
+			values:  `$1`,
+		},
+		{
+			v:       &mock{},
+			desc:    "mock",
+			columns: `"automatic","tagged","one_two","CamelCase"`,
+			values:  `$1, $2, $3, $4`,
+		},
+		{
+			v:       mockMultiEmbed{},
+			desc:    "multiembed",
+			columns: `"a","automatic","tagged","one_two","CamelCase","b","number","c"`,
+			values:  `$1, $2, $3, $4, $5, $6, $7, $8`,
+		},
+		{
+			v:       &jsonMock{},
+			desc:    "json",
+			columns: `"id","name","code","is_active","theme","created_at","modified_at"`,
+			values:  `$1, $2, $3, $4, $5, $6, $7`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.InsertColumns(tc.v); tc.columns != got {
+				t.Errorf("expected columns to be %v, got %v instead", tc.columns, got)
+			}
+			if got := pgtools.InsertValues(tc.v); tc.values != got {
+				t.Errorf("expected values to be %v, got %v instead", tc.values, got)
+			}
+			want := `INSERT INTO "table" (` + tc.columns + `) VALUES (` + tc.values + `)`
+			if got := pgtools.Insert("table", tc.v); want != got {
+				t.Errorf("expected statement to be %v, got %v instead", want, got)
+			}
+		})
+	}
+}
+
+func ExampleInsertColumnsMap() {
+	cols, values, args := pgtools.InsertColumnsMap(map[string]interface{}{
+		"name":  "Alice",
+		"email": "alice@example.com",
+	})
+	fmt.Println(`INSERT INTO "user" (` + cols + `) VALUES (` + values + `)`)
+	fmt.Println(args)
+	// Output:
+	// INSERT INTO "user" ("email","name") VALUES ($1, $2)
+	// [alice@example.com Alice]
+}
+
+func TestInsertColumnsMap(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc    string
+		m       map[string]interface{}
+		columns string
+		values  string
+		args    []interface{}
+	}{
+		{
+			desc: "nil",
+		},
+		{
+			desc: "empty",
+			m:    map[string]interface{}{},
+		},
+		{
+			desc:    "sorted",
+			m:       map[string]interface{}{"updated_at": nil, "age": 42, "Name": "Alice", "address.city": "Springfield"},
+			columns: `"Name","address.city","age","updated_at"`,
+			values:  `$1, $2, $3, $4`,
+			args:    []interface{}{"Alice", "Springfield", 42, nil},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			columns, values, args := pgtools.InsertColumnsMap(tc.m)
+			if columns != tc.columns {
+				t.Errorf("expected columns to be %v, got %v instead", tc.columns, columns)
+			}
+			if values != tc.values {
+				t.Errorf("expected values to be %v, got %v instead", tc.values, values)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("expected arguments to be %v, got %v instead", tc.args, args)
+			}
+		})
+	}
+}
+
+func TestInsertSchema(t *testing.T) {
+	t.Parallel()
+	for table, want := range map[string]string{
+		"events":           `INSERT INTO "events" ("automatic","tagged","one_two","CamelCase") VALUES ($1, $2, $3, $4)`,
+		"analytics.events": `INSERT INTO "analytics"."events" ("automatic","tagged","one_two","CamelCase") VALUES ($1, $2, $3, $4)`,
+	} {
+		if got := pgtools.Insert(table, mock{}); got != want {
+			t.Errorf("expected statement to be %v, got %v instead", want, got)
+		}
+	}
+}
+
+func TestInsertValuesN(t *testing.T) {
+	t.Parallel()
+	type pair struct {
+		A string
+		B string
+	}
+	testCases := []struct {
+		v    interface{}
+		rows int
+		desc string
+		want string
+	}{
+		{
+			v:    nil,
+			rows: 2,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			rows: 2,
+			desc: "empty",
+		},
+		{
+			v:    pair{},
+			rows: 0,
+			desc: "zero",
+		},
+		{
+			v:    pair{},
+			rows: 1,
+			desc: "one",
+			want: `($1,$2)`,
+		},
+		{
+			v:    pair{},
+			rows: 3,
+			desc: "pair",
+			want: `($1,$2),($3,$4),($5,$6)`,
+		},
+		{
+			v:    &mock{},
+			rows: 2,
+			desc: "mock",
+			want: `($1,$2,$3,$4),($5,$6,$7,$8)`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.InsertValuesN(tc.v, tc.rows); tc.want != got {
+				t.Errorf("expected values to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func ExampleBulkInsert() {
+	type pair struct {
+		A string
+		B int
+	}
+	sql, args, err := pgtools.BulkInsert("pairs", []pair{{"x", 1}, {"y", 2}})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(sql)
+	fmt.Println(args)
+	// Output:
+	// INSERT INTO "pairs" ("a","b") VALUES ($1,$2),($3,$4)
+	// [x 1 y 2]
+}
+
+func TestBulkInsert(t *testing.T) {
+	t.Parallel()
+	type pair struct {
+		A string
+		B int
+	}
+	testCases := []struct {
+		desc  string
+		table string
+		vs    interface{}
+		sql   string
+		args  []interface{}
+		err   string
+	}{
+		{
+			desc: "nil",
+			vs:   nil,
+			err:  "pgtools: cannot bulk insert <nil>: not a slice",
+		},
+		{
+			desc: "struct",
+			vs:   pair{},
+			err:  "pgtools: cannot bulk insert pgtools_test.pair: not a slice",
+		},
+		{
+			desc: "empty",
+			vs:   []pair{},
+			err:  "pgtools: cannot bulk insert an empty slice",
+		},
+		{
+			desc: "scalars",
+			vs:   []int{1, 2},
+			err:  "pgtools: cannot bulk insert []int: elements aren't structs",
+		},
+		{
+			desc: "no columns",
+			vs:   []emptyEmbed{{}},
+			err:  "pgtools: pgtools_test.emptyEmbed has no columns",
+		},
+		{
+			desc: "no table",
+			vs:   []pair{{"x", 1}},
+			err:  "pgtools: cannot bulk insert []pgtools_test.pair: no table name, and pgtools_test.pair doesn't implement Tabler",
+		},
+		{
+			desc:  "one",
+			table: "pairs",
+			vs:    []pair{{"x", 1}},
+			sql:   `INSERT INTO "pairs" ("a","b") VALUES ($1,$2)`,
+			args:  []interface{}{"x", 1},
+		},
+		{
+			desc:  "pointers",
+			table: "analytics.pairs",
+			vs:    []*pair{{"x", 1}, nil, {"z", 3}},
+			sql:   `INSERT INTO "analytics"."pairs" ("a","b") VALUES ($1,$2),($3,$4),($5,$6)`,
+			args:  []interface{}{"x", 1, nil, nil, "z", 3},
+		},
+		{
+			desc: "tabler",
+			vs:   []account{{ID: 1, Email: "alice@example.com"}},
+			sql:  `INSERT INTO "accounts" ("email") VALUES ($1)`,
+			args: []interface{}{"alice@example.com"},
+		},
+		{
+			desc:  "readonly",
+			table: "users",
+			vs:    []readonlyMock{{ID: 1, Name: "a", Email: "a@example.com"}, {ID: 2, Name: "b", Email: "b@example.com"}},
+			sql:   `INSERT INTO "users" ("name","email") VALUES ($1,$2),($3,$4)`,
+			args:  []interface{}{"a", "a@example.com", "b", "b@example.com"},
+		},
+		{
+			desc:  "too many parameters",
+			table: "pairs",
+			vs:    make([]pair, 32768),
+			err:   "pgtools: cannot bulk insert 32768 rows: 65536 parameters exceed the limit of 65535",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			sql, args, err := pgtools.BulkInsert(tc.table, tc.vs)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error to be %q, got %v instead", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sql != tc.sql {
+				t.Errorf("expected SQL to be %v, got %v instead", tc.sql, sql)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("expected args to be %v, got %v instead", tc.args, args)
+			}
+		})
+	}
+}
+
+type readonlyMock struct {
+	ID        int64     `db:"id,readonly"`
+	Name      string    `db:"name"`
+	Meta      Theme     `db:"meta,json,readonly"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at,readonly"`
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+	v := readonlyMock{ID: 1, Name: "Alice", Email: "alice@example.com"}
+	if want, got := `"id","name","meta","email","created_at"`, pgtools.Wildcard(v); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+	if want, got := `INSERT INTO "user" ("name","email") VALUES ($1, $2)`, pgtools.Insert("user", v); want != got {
+		t.Errorf("expected statement to be %v, got %v instead", want, got)
+	}
+	if want, got := `($1,$2),($3,$4)`, pgtools.InsertValuesN(v, 2); want != got {
+		t.Errorf("expected values to be %v, got %v instead", want, got)
+	}
+	if want, got := `@name, @email`, pgtools.WildcardNamed(v); want != got {
+		t.Errorf("expected named parameters to be %v, got %v instead", want, got)
+	}
+	if want, got := `"name"=$2,"email"=$3`, pgtools.UpdateSet(v, 2); want != got {
+		t.Errorf("expected assignments to be %v, got %v instead", want, got)
+	}
+	if want, got := `ON CONFLICT ("email") DO UPDATE SET "name"=EXCLUDED."name"`, pgtools.OnConflictUpdate(v, []string{"email"}); want != got {
+		t.Errorf("expected clause to be %v, got %v instead", want, got)
+	}
+	if want, got := []interface{}{"Alice", "alice@example.com"}, pgtools.Args(v); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected args to be %v, got %v instead", want, got)
+	}
+	if got := pgtools.NamedArgs(v); got["id"] != int64(1) || len(got) != 5 {
+		t.Errorf("expected named args to include readonly columns, got %v instead", got)
+	}
+}
+
+type prefixMock struct {
+	ID       string
+	Address  address  `db:"address_,prefix"`
+	Shipping *address `db:"shipping_,prefix"`
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+	v := prefixMock{ID: "1", Address: address{Street: "Rua Augusta", City: "Lisbon"}}
+	if want, got := `"id","address_street","address_city","shipping_street","shipping_city"`, pgtools.Wildcard(v); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+	if want, got := `INSERT INTO "customer" ("id","address_street","address_city","shipping_street","shipping_city") VALUES ($1, $2, $3, $4, $5)`, pgtools.Insert("customer", v); want != got {
+		t.Errorf("expected statement to be %v, got %v instead", want, got)
+	}
+	if want, got := []interface{}{"1", "Rua Augusta", "Lisbon", nil, nil}, pgtools.Args(v); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected args to be %v, got %v instead", want, got)
+	}
+}
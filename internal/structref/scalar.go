@@ -0,0 +1,81 @@
+package structref
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// scalars registered with RegisterScalar.
+var scalars = struct {
+	mu sync.RWMutex // guards following
+	m  map[reflect.Type]struct{}
+}{
+	m: map[reflect.Type]struct{}{
+		reflect.TypeOf(time.Time{}): {},
+	},
+}
+
+// RegisterScalar registers a struct type that maps to a single column,
+// so its fields aren't mapped to columns of their own.
+func RegisterScalar(t reflect.Type) {
+	scalars.mu.Lock()
+	defer scalars.mu.Unlock()
+	scalars.m[t] = struct{}{}
+}
+
+// IsScalar reports whether a struct type maps to a single column.
+//
+// This is the case for time.Time, types registered with RegisterScalar,
+// and types implementing sql.Scanner or driver.Valuer, such as sql.NullString and pgtype.Text,
+// as they're responsible for converting themselves from and to a column value.
+// Methods promoted from an embedded field don't count, so a model embedding sql.NullString
+// keeps its other fields; register a type that embeds one and overrides its methods instead.
+func IsScalar(t reflect.Type) bool {
+	if implements(t, scannerType, "Scan") || implements(t, valuerType, "Value") {
+		return true
+	}
+	scalars.mu.RLock()
+	defer scalars.mu.RUnlock()
+	_, ok := scalars.m[t]
+	return ok
+}
+
+// implements reports whether t or a pointer to it implements iface with a method it doesn't get
+// from an embedded field.
+func implements(t, iface reflect.Type, method string) bool {
+	if !t.Implements(iface) && !reflect.PtrTo(t).Implements(iface) {
+		return false
+	}
+	return !promoted(t, method)
+}
+
+// promoted reports whether the method of struct t is promoted from one of its embedded fields.
+func promoted(t reflect.Type, method string) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		if _, ok := f.Type.MethodByName(method); ok {
+			return true
+		}
+		// Methods with a pointer receiver are promoted to a pointer to t.
+		if f.Type.Kind() != reflect.Ptr {
+			if _, ok := reflect.PtrTo(f.Type).MethodByName(method); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
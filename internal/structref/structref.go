@@ -16,17 +16,93 @@ import (
 	"strings"
 )
 
-var dbStructTagKey = "db"
+// DefaultTagKey is the struct tag key used when none is given.
+const DefaultTagKey = "db"
 
 type toTraverse struct {
 	Type         reflect.Type
 	IndexPrefix  []int
 	ColumnPrefix string
+	ReadOnly     bool
+
+	// FlatPrefix is set when columns of the struct are prefixed with ColumnPrefix
+	// without a separator, due to the "prefix" tag option.
+	FlatPrefix bool
+}
+
+// Column mapped from a struct field.
+type Column struct {
+	// Index of the struct field, as used by reflect.Value.FieldByIndex.
+	Index []int
+
+	// JSON is set when the field has the "json" tag option.
+	JSON bool
+
+	// ReadOnly is set when the field, or the struct containing it, has the "readonly" or "generated" tag option.
+	ReadOnly bool
+
+	// Generated is set when the field has the "generated" tag option,
+	// for a column such as GENERATED ALWAYS AS ... STORED, which can be read but not written.
+	Generated bool
+
+	// PrimaryKey is set when the field has the "pk" tag option.
+	PrimaryKey bool
+
+	// Default is the SQL expression used instead of NULL, set by the "default" tag option,
+	// as in `db:"count,default=0"`. It's empty if the option isn't set.
+	Default string
 }
 
 // GetColumnToFieldIndexMap containing where columns should be mapped.
 func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
-	result := make(map[string][]int, structType.NumField())
+	return GetColumnToFieldIndexMapWithTag(structType, DefaultTagKey)
+}
+
+// GetColumnToFieldIndexMapWithTag is like GetColumnToFieldIndexMap, but reads column names
+// from the given struct tag key instead of "db".
+func GetColumnToFieldIndexMapWithTag(structType reflect.Type, tagKey string) map[string][]int {
+	columns := GetColumns(structType, Options{TagKey: tagKey})
+	result := make(map[string][]int, len(columns))
+	for name, c := range columns {
+		result[name] = c.Index
+	}
+	return result
+}
+
+// Options for reading the columns of a struct.
+type Options struct {
+	// TagKey is the struct tag key to read column names and options from.
+	// If empty, DefaultTagKey is used.
+	TagKey string
+
+	// Namer converts the name of a field without an explicit column name to a column name.
+	// If nil, the field name is converted to snake_case.
+	Namer func(string) string
+
+	// Separator between the column of a nested struct and the columns of its fields.
+	// If empty, "." is used.
+	Separator string
+
+	// OnDuplicate is called when more than one field maps to the same column.
+	// The column is mapped to the field found first, which is the least nested one, and the other is skipped.
+	OnDuplicate func(column string, kept, skipped []int)
+}
+
+// GetColumns returns the columns of a struct.
+func GetColumns(structType reflect.Type, opts Options) map[string]Column {
+	tagKey := opts.TagKey
+	if tagKey == "" {
+		tagKey = DefaultTagKey
+	}
+	namer := opts.Namer
+	if namer == nil {
+		namer = toSnakeCase
+	}
+	nestedSeparator := opts.Separator
+	if nestedSeparator == "" {
+		nestedSeparator = "."
+	}
+	result := make(map[string]Column, structType.NumField())
 	jsonColumns := map[string]struct{}{}
 	var queue []*toTraverse
 	queue = append(queue, &toTraverse{Type: structType, IndexPrefix: nil, ColumnPrefix: ""})
@@ -42,7 +118,7 @@ func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
 				continue
 			}
 
-			dbTag, dbTagPresent := field.Tag.Lookup(dbStructTagKey)
+			dbTag, dbTagPresent := field.Tag.Lookup(tagKey)
 			var options tagOptions
 			if dbTagPresent {
 				dbTag, options = parseTag(dbTag)
@@ -58,14 +134,20 @@ func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
 
 			columnPart := dbTag
 			if !dbTagPresent || columnPart == "" {
-				columnPart = toSnakeCase(field.Name)
+				columnPart = namer(field.Name)
 			}
 
 			childType := field.Type
 			if field.Type.Kind() == reflect.Ptr {
 				childType = field.Type.Elem()
 			}
-			if childType.Kind() == reflect.Struct {
+			// A scalar struct, such as time.Time, is a single column, even if embedded.
+			scalar := childType.Kind() == reflect.Struct && IsScalar(childType)
+			if scalar && field.PkgPath != "" {
+				// Unexported embedded scalars aren't columns.
+				continue
+			}
+			if childType.Kind() == reflect.Struct && !scalar {
 				if field.Anonymous {
 					// If "db" tag is present for embedded struct
 					// use it with "." to prefix all column from the embedded struct.
@@ -74,8 +156,16 @@ func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
 				}
 			}
 
-			column := buildColumn(traversal.ColumnPrefix, columnPart)
-			if childType.Kind() == reflect.Struct {
+			separator := nestedSeparator
+			if traversal.FlatPrefix {
+				separator = ""
+			}
+			column := buildColumn(separator, traversal.ColumnPrefix, columnPart)
+			readOnly := traversal.ReadOnly || options.Contains("readonly") || options.Contains("generated")
+			// The "prefix" tag option flattens a nested struct into columns prefixed with its column name,
+			// such as address_street and address_city for `db:"address_,prefix"`.
+			flatPrefix := childType.Kind() == reflect.Struct && !scalar && options.Contains("prefix") && !options.Contains("json")
+			if childType.Kind() == reflect.Struct && !scalar {
 				if options.Contains("json") {
 					jsonColumns[column] = struct{}{}
 				} else {
@@ -83,15 +173,27 @@ func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
 						Type:         childType,
 						IndexPrefix:  index,
 						ColumnPrefix: column,
+						ReadOnly:     readOnly,
+						FlatPrefix:   flatPrefix,
 					})
 				}
 			}
-			if !field.Anonymous {
+			if (!field.Anonymous || scalar) && !flatPrefix {
 				_, self := jsonColumns[column]
 				_, parent := jsonColumns[traversal.ColumnPrefix]
 				if !self || !parent {
-					if _, exists := result[column]; !exists {
-						result[column] = index
+					if c, exists := result[column]; !exists {
+						def, _ := options.Value("default")
+						result[column] = Column{
+							Index:      index,
+							JSON:       options.Contains("json"),
+							ReadOnly:   readOnly,
+							Generated:  options.Contains("generated"),
+							PrimaryKey: options.Contains("pk"),
+							Default:    def,
+						}
+					} else if opts.OnDuplicate != nil {
+						opts.OnDuplicate(column, c.Index, index)
 					}
 				}
 			}
@@ -101,14 +203,14 @@ func GetColumnToFieldIndexMap(structType reflect.Type) map[string][]int {
 	return result
 }
 
-func buildColumn(parts ...string) string {
+func buildColumn(separator string, parts ...string) string {
 	var notEmptyParts []string
 	for _, p := range parts {
 		if p != "" {
 			notEmptyParts = append(notEmptyParts, p)
 		}
 	}
-	return strings.Join(notEmptyParts, ".")
+	return strings.Join(notEmptyParts, separator)
 }
 
 var (
@@ -1,7 +1,9 @@
 package structref
 
 import (
+	"database/sql"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -85,6 +87,20 @@ func TestGetColumnToFieldIndexMap(t *testing.T) {
 				"renamed.name": {1, 1},
 			},
 		},
+		{
+			name: "ignored",
+			v: struct {
+				Before  string
+				Ignored string      `db:"-"`
+				Theme   NestedTheme `db:"-"`
+				Embed   `db:"-"`
+				After   string
+			}{},
+			want: map[string][]int{
+				"before": {0},
+				"after":  {4},
+			},
+		},
 		{
 			name: "implicit",
 			v:    embedImplicit{},
@@ -108,3 +124,199 @@ func TestGetColumnToFieldIndexMap(t *testing.T) {
 		})
 	}
 }
+
+func TestGetColumnToFieldIndexMapWithTag(t *testing.T) {
+	type legacy struct {
+		ID      string `sql:"legacy_id" db:"id"`
+		Name    string `db:"name"`
+		Ignored string `sql:"-"`
+	}
+	want := map[string][]int{
+		"legacy_id": {0},
+		"name":      {1},
+	}
+	if got := GetColumnToFieldIndexMapWithTag(reflect.TypeOf(legacy{}), "sql"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumnToFieldIndexMapWithTag() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumns(t *testing.T) {
+	type Meta struct {
+		Version int
+	}
+	type model struct {
+		ID        string    `db:"id,readonly"`
+		Name      string    `db:"name"`
+		Meta      Meta      `db:"meta,json,readonly"`
+		Nested    Meta      `db:"nested,readonly"`
+		CreatedAt time.Time `db:",readonly"`
+		Count     int       `db:"count,default=0"`
+		Key       string    `db:"key,pk"`
+		FullName  string    `db:"full_name,generated"`
+	}
+	want := map[string]Column{
+		"id":             {Index: []int{0}, ReadOnly: true},
+		"name":           {Index: []int{1}},
+		"meta":           {Index: []int{2}, JSON: true, ReadOnly: true},
+		"nested":         {Index: []int{3}, ReadOnly: true},
+		"nested.version": {Index: []int{3, 0}, ReadOnly: true},
+		"created_at":     {Index: []int{4}, ReadOnly: true},
+		"count":          {Index: []int{5}, Default: "0"},
+		"key":            {Index: []int{6}, PrimaryKey: true},
+		"full_name":      {Index: []int{7}, ReadOnly: true, Generated: true},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsNamer(t *testing.T) {
+	type Embed struct {
+		PlayCount int
+	}
+	type model struct {
+		FirstName string
+		LastName  string `db:"surname"`
+		Embed
+	}
+	want := map[string]Column{
+		"FIRSTNAME": {Index: []int{0}},
+		"surname":   {Index: []int{1}},
+		"PLAYCOUNT": {Index: []int{2, 0}},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{Namer: strings.ToUpper}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsPrefix(t *testing.T) {
+	type Address struct {
+		Street string
+		City   string
+	}
+	type Timestamps struct {
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type model struct {
+		ID         string
+		Address    Address  `db:"address_,prefix"`
+		Shipping   *Address `db:"shipping_,prefix,readonly"`
+		Timestamps `db:"ts_,prefix"`
+		Nested     Address `db:"nested"`
+	}
+	want := map[string]Column{
+		"id":              {Index: []int{0}},
+		"address_street":  {Index: []int{1, 0}},
+		"address_city":    {Index: []int{1, 1}},
+		"shipping_street": {Index: []int{2, 0}, ReadOnly: true},
+		"shipping_city":   {Index: []int{2, 1}, ReadOnly: true},
+		"ts_created_at":   {Index: []int{3, 0}},
+		"ts_updated_at":   {Index: []int{3, 1}},
+		"nested":          {Index: []int{4}},
+		"nested.street":   {Index: []int{4, 0}},
+		"nested.city":     {Index: []int{4, 1}},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsSeparator(t *testing.T) {
+	type Geo struct {
+		Lat float64
+	}
+	type Address struct {
+		City string
+		Geo  Geo
+	}
+	type model struct {
+		ID       string
+		Address  Address
+		Shipping Address `db:"ship_,prefix"`
+		Dotted   string  `db:"a.b"`
+	}
+	want := map[string]Column{
+		"id":                {Index: []int{0}},
+		"address":           {Index: []int{1}},
+		"address__city":     {Index: []int{1, 0}},
+		"address__geo":      {Index: []int{1, 1}},
+		"address__geo__lat": {Index: []int{1, 1, 0}},
+		"ship_city":         {Index: []int{2, 0}},
+		"ship_geo":          {Index: []int{2, 1}},
+		"ship_geo__lat":     {Index: []int{2, 1, 0}},
+		"a.b":               {Index: []int{3}},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{Separator: "__"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsOnDuplicate(t *testing.T) {
+	type Embed struct {
+		Name string
+		Code string
+	}
+	type model struct {
+		Embed
+		Name  string
+		Alias string `db:"code"`
+	}
+	type duplicate struct {
+		column        string
+		kept, skipped []int
+	}
+	var got []duplicate
+	GetColumns(reflect.TypeOf(model{}), Options{
+		OnDuplicate: func(column string, kept, skipped []int) {
+			got = append(got, duplicate{column, kept, skipped})
+		},
+	})
+	want := []duplicate{
+		{column: "name", kept: []int{1}, skipped: []int{0, 0}},
+		{column: "code", kept: []int{2}, skipped: []int{0, 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnDuplicate() calls = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsScalar(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	RegisterScalar(reflect.TypeOf(point{}))
+	type model struct {
+		ID        sql.NullInt64
+		Name      *sql.NullString
+		CreatedAt time.Time
+		Location  point `db:"loc"`
+		time.Time
+	}
+	want := map[string]Column{
+		"id":         {Index: []int{0}},
+		"name":       {Index: []int{1}},
+		"created_at": {Index: []int{2}},
+		"loc":        {Index: []int{3}},
+		"time":       {Index: []int{4}},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestGetColumnsEmbeddedScanner(t *testing.T) {
+	type model struct {
+		sql.NullString
+		ID   int
+		Name string
+	}
+	want := map[string]Column{
+		"null_string": {Index: []int{0}},
+		"id":          {Index: []int{1}},
+		"name":        {Index: []int{2}},
+	}
+	if got := GetColumns(reflect.TypeOf(model{}), Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetColumns() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package pgtools
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// maxJSONBuildObjectPairs is the number of key and value pairs a jsonb_build_object call can have,
+// as PostgreSQL functions accept up to 100 arguments.
+const maxJSONBuildObjectPairs = 50
+
+// JSONBuildObject returns an expression building a JSON object with the same columns as Wildcard,
+// using the column names as keys, such as:
+//
+//	jsonb_build_object('id', "id", 'full_name', "full_name")
+//
+// Use it to select a row as a single JSON value. Objects with more than 50 columns are built
+// by concatenating multiple jsonb_build_object calls, due to the limit of function arguments.
+// If there are no columns, an empty string is returned.
+func JSONBuildObject(v interface{}) string {
+	columns := fields(v, structref.DefaultTagKey)
+	return jsonBuildObject(columns, columns)
+}
+
+// JSONBuildObjectCamelCase returns an expression like JSONBuildObject, using the names of the
+// Go fields in camelCase as keys instead of the column names, such as:
+//
+//	jsonb_build_object('id', "id", 'fullName', "full_name")
+//
+// The keys of nested fields are joined with a dot, as in 'address.streetName'.
+// Fields promoted from an embedded struct aren't nested, as with Fields, so their keys don't include its name.
+func JSONBuildObjectCamelCase(v interface{}) string {
+	rv := typeOf(v)
+	if rv == nil {
+		return ""
+	}
+	all := cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).all
+	keys := make([]string, len(all.names))
+	for i, field := range all.fields {
+		keys[i] = camelCaseKey(rv, field.Index)
+	}
+	return jsonBuildObject(keys, all.names)
+}
+
+// camelCaseKey returns the names of the fields at index in camelCase, joined with a dot.
+// Embedded structs without a name in the struct tag are skipped, as their fields are promoted.
+func camelCaseKey(t reflect.Type, index []int) string {
+	parts := make([]string, 0, len(index))
+	for n, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f := t.Field(i)
+		t = f.Type
+		if f.Anonymous && n != len(index)-1 {
+			if name := strings.SplitN(f.Tag.Get(structref.DefaultTagKey), ",", 2)[0]; name == "" {
+				continue
+			}
+		}
+		parts = append(parts, lowerCamelCase(f.Name))
+	}
+	return strings.Join(parts, ".")
+}
+
+// jsonBuildObject returns an expression building a JSON object with the given keys and columns.
+func jsonBuildObject(keys, columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for start := 0; start < len(columns); start += maxJSONBuildObjectPairs {
+		if start != 0 {
+			b.WriteString(` || `)
+		}
+		end := start + maxJSONBuildObjectPairs
+		if end > len(columns) {
+			end = len(columns)
+		}
+		b.WriteString(`jsonb_build_object(`)
+		for i := start; i < end; i++ {
+			if i != start {
+				b.WriteString(`, `)
+			}
+			b.WriteString(`'`)
+			b.WriteString(strings.ReplaceAll(keys[i], `'`, `''`))
+			b.WriteString(`', `)
+			writeIdentifier(&b, columns[i])
+		}
+		b.WriteString(`)`)
+	}
+	return b.String()
+}
+
+// lowerCamelCase converts a Go field name to camelCase, such as FullName to fullName.
+// A leading acronym is lowercased as a whole, so ID becomes id, and URLPath becomes urlPath.
+func lowerCamelCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	upper := true
+	for i, r := range s {
+		if upper && unicode.IsUpper(r) {
+			// Keep the last upper case letter of an acronym followed by a lower case letter, as in URLPath.
+			if next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):]); i != 0 && unicode.IsLower(next) {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		upper = false
+		b.WriteString(s[i:])
+		return b.String()
+	}
+	return b.String()
+}
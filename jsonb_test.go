@@ -0,0 +1,96 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleJSONBuildObject() {
+	sql := "SELECT " + pgtools.JSONBuildObject(User{}) + ` FROM "user" WHERE id = $1`
+	fmt.Println(sql)
+	// Output:
+	// SELECT jsonb_build_object('username', "username", 'full_name', "full_name", 'email', "email", 'id', "id", 'theme', "theme") FROM "user" WHERE id = $1
+}
+
+func TestJSONBuildObject(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v         interface{}
+		desc      string
+		want      string
+		camelCase string
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+		},
+		{
+			v:         &User{},
+			desc:      "user",
+			want:      `jsonb_build_object('username', "username", 'full_name', "full_name", 'email', "email", 'id', "id", 'theme', "theme")`,
+			camelCase: `jsonb_build_object('username', "username", 'fullName', "full_name", 'email', "email", 'alias', "id", 'theme', "theme")`,
+		},
+		{
+			v: struct {
+				ID      string
+				URLPath string
+				It      string `db:"it's"`
+				Address address
+			}{},
+			desc:      "nested",
+			want:      `jsonb_build_object('id', "id", 'url_path', "url_path", 'it''s', "it's", 'address.street', "address.street", 'address.city', "address.city", 'address', "address")`,
+			camelCase: `jsonb_build_object('id', "id", 'urlPath', "url_path", 'it', "it's", 'address.street', "address.street", 'address.city', "address.city", 'address', "address")`,
+		},
+		{
+			v: struct {
+				*Timestamps
+				FullName string
+			}{},
+			desc:      "embedded",
+			want:      `jsonb_build_object('created_at', "created_at", 'modified_at', "modified_at", 'full_name', "full_name")`,
+			camelCase: `jsonb_build_object('createdAt', "created_at", 'modifiedAt', "modified_at", 'fullName', "full_name")`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.JSONBuildObject(tc.v); got != tc.want {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+			if got := pgtools.JSONBuildObjectCamelCase(tc.v); got != tc.camelCase {
+				t.Errorf("expected camel case expression to be %v, got %v instead", tc.camelCase, got)
+			}
+		})
+	}
+}
+
+func TestJSONBuildObjectLarge(t *testing.T) {
+	t.Parallel()
+	fields := make([]reflect.StructField, 120)
+	for i := range fields {
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("F%d", i),
+			Type: reflect.TypeOf(""),
+		}
+	}
+	v := reflect.New(reflect.StructOf(fields)).Interface()
+	got := pgtools.JSONBuildObject(v)
+	if n := strings.Count(got, "jsonb_build_object("); n != 3 {
+		t.Errorf("expected 3 calls to jsonb_build_object, got %d instead: %v", n, got)
+	}
+	if want := `jsonb_build_object('f0', "f0"`; !strings.HasPrefix(got, want) {
+		t.Errorf("expected expression to start with %v, got %v instead", want, got)
+	}
+	if want := `'f49', "f49") || jsonb_build_object('f50', "f50"`; !strings.Contains(got, want) {
+		t.Errorf("expected expression to contain %v, got %v instead", want, got)
+	}
+}
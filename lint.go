@@ -0,0 +1,67 @@
+package pgtools
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// Lint returns a list of problems found in the columns of a struct, such as:
+//
+//   - the value isn't a struct or a pointer to a struct, or it has no columns.
+//   - more than one field maps to the same column, such as when two nested structs have a Name field.
+//     Only the least nested field is mapped, so the other is silently ignored by Fields and Wildcard.
+//   - column names that are empty, which would generate invalid SQL.
+//   - column names that contain a double quote, which pgtools escapes, but other tools and handwritten SQL
+//     must escape too, as "" inside a quoted identifier.
+//   - column names that only differ by case, such as UserID and userid, which are the same column
+//     if the table was created with unquoted identifiers, as PostgreSQL folds them to lowercase.
+//
+// If no problems are found, nil is returned.
+// You can use it in a test to validate all your models, as it reads columns the same way Fields does.
+func Lint(v interface{}) []string {
+	if _, err := FieldsError(v); err != nil {
+		return []string{err.Error()}
+	}
+	rv := typeOf(v)
+	var problems []string
+	columns := structref.GetColumns(rv, structref.Options{
+		OnDuplicate: func(column string, kept, skipped []int) {
+			problems = append(problems, fmt.Sprintf("pgtools: duplicate column %q: field %s is ignored in favor of %s",
+				column, fieldPath(rv, skipped), fieldPath(rv, kept)))
+		},
+	})
+	folded := map[string]string{}
+	for _, column := range Fields(v) {
+		lower := strings.ToLower(column)
+		if other, ok := folded[lower]; ok {
+			problems = append(problems, fmt.Sprintf("pgtools: column %q of field %s only differs by case from column %q of field %s",
+				column, fieldPath(rv, columns[column].Index), other, fieldPath(rv, columns[other].Index)))
+		} else {
+			folded[lower] = column
+		}
+		switch {
+		case column == "":
+			problems = append(problems, fmt.Sprintf("pgtools: empty column name for field %s", fieldPath(rv, columns[column].Index)))
+		case strings.ContainsRune(column, '"'):
+			problems = append(problems, fmt.Sprintf("pgtools: column %q of field %s contains a double quote, which must be escaped outside of pgtools", column, fieldPath(rv, columns[column].Index)))
+		}
+	}
+	return problems
+}
+
+// fieldPath returns the name of the field of the struct at the given index, such as Address.Street.
+func fieldPath(t reflect.Type, index []int) string {
+	names := make([]string, 0, len(index))
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f := t.Field(i)
+		names = append(names, f.Name)
+		t = f.Type
+	}
+	return strings.Join(names, ".")
+}
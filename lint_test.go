@@ -0,0 +1,76 @@
+package pgtools_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	type named struct {
+		Name string
+	}
+	type duplicate struct {
+		ID      string
+		Name    string
+		Author  named `db:"author"`
+		Embed   named
+		Pointer *named
+		named
+	}
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want []string
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+			want: []string{"pgtools: cannot get fields of nil"},
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+			want: []string{"pgtools: pgtools_test.emptyEmbed has no columns"},
+		},
+		{
+			v:    &mock{},
+			desc: "valid",
+		},
+		{
+			v:    customer{},
+			desc: "nested",
+		},
+		{
+			v:    &duplicate{},
+			desc: "duplicate",
+			want: []string{`pgtools: duplicate column "name": field named.Name is ignored in favor of Name`},
+		},
+		{
+			v: struct {
+				Quoted string `db:"quo\"ted"`
+			}{},
+			desc: "quote",
+			want: []string{`pgtools: column "quo\"ted" of field Quoted contains a double quote, which must be escaped outside of pgtools`},
+		},
+		{
+			v: struct {
+				UserID string `db:"UserID"`
+				UserId string `db:"userid"`
+			}{},
+			desc: "case",
+			want: []string{`pgtools: column "userid" of field UserId only differs by case from column "UserID" of field UserID`},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.Lint(tc.v); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected problems to be %q, got %q instead", tc.want, got)
+			}
+		})
+	}
+}
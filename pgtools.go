@@ -3,7 +3,10 @@
 package pgtools
 
 import (
-	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
@@ -18,10 +21,15 @@ import (
 // To make usage simpler in the happy path, Wildcard doesn't return an error.
 // However, this means you should check if the value generated by the function
 // is valid (panic is not used as it would introduce unwanted risk).
+// You can use FieldsError to validate your types.
 //
 // The "db" key in the struct field's tag can specify the "json" option
 // when a JSON or JSONB data type is used in PostgreSQL.
 //
+// Every column is double-quoted, so reserved words such as "order" can be used as column names,
+// and a double quote in a column name is escaped as "", as in SQL.
+// The helpers writing data, such as Insert and UpdateSet, quote columns the same way.
+//
 // It is useful to ensure scany works after adding a field to the databsase,
 // and for performance reasons too by reducing the number of places where
 // a wildcard (*) is used for convenience in SELECT queries.
@@ -33,7 +41,183 @@ import (
 // If you're curious about doing this "in the other direction", see
 // https://github.com/golang/pkgsite/blob/2d3ade3c90634f9afed7aa772e53a62bb433447a/internal/database/reflect.go#L20-L46
 func Wildcard(v interface{}) string {
-	elems := Fields(v)
+	return wildcardOf(typeOf(v), structref.DefaultTagKey, "", false)
+}
+
+// WildcardWithAlias returns an expression like Wildcard, but qualifies each column
+// with the given table alias, as in:
+//
+//	"u"."id" as "id","u"."name" as "name"
+//
+// Every column is aliased so scany can map it back, and this can be used to avoid
+// column ambiguity when joining multiple tables.
+// The alias is quoted as a single identifier, so a dot in it isn't a schema separator.
+func WildcardWithAlias(v interface{}, alias string) string {
+	var qualifier strings.Builder
+	if alias != "" {
+		writeIdentifier(&qualifier, alias)
+	}
+	return wildcardOf(typeOf(v), structref.DefaultTagKey, qualifier.String(), true)
+}
+
+// WildcardWithTable returns an expression like Wildcard, but qualifies each column
+// with the given table name, as in:
+//
+//	"users"."id","users"."name"
+//
+// Unlike WildcardWithAlias, only columns containing a dot are aliased.
+// The table can be qualified with a schema, as in "analytics.events", which is quoted as "analytics"."events".
+func WildcardWithTable(v interface{}, table string) string {
+	var qualifier string
+	if table != "" {
+		qualifier = quoteTable(table)
+	}
+	return wildcardOf(typeOf(v), structref.DefaultTagKey, qualifier, false)
+}
+
+// WildcardExcept returns an expression like Wildcard, without the excluded columns.
+//
+// Excluded names are matched against the column names returned by Fields, not the Go field names,
+// and names that don't exist are ignored.
+func WildcardExcept(v interface{}, exclude ...string) string {
+	info := typeInfoOf(typeOf(v), structref.DefaultTagKey)
+	elems := make([]string, 0, len(info.all.names))
+	for _, c := range info.all.names {
+		if !contains(exclude, c) {
+			elems = append(elems, c)
+		}
+	}
+	return wildcard(elems, info.defaults, "", false)
+}
+
+// WildcardOnly returns an expression like Wildcard, with only the included columns.
+// Columns are listed in the same order as Wildcard, regardless of the order they're included,
+// and columns containing a dot are aliased like Wildcard does.
+//
+// Included names are matched against the column names returned by Fields, not the Go field names,
+// and names that don't exist are ignored.
+func WildcardOnly(v interface{}, include ...string) string {
+	info := typeInfoOf(typeOf(v), structref.DefaultTagKey)
+	elems := make([]string, 0, len(include))
+	for _, c := range info.all.names {
+		if contains(include, c) {
+			elems = append(elems, c)
+		}
+	}
+	return wildcard(elems, info.defaults, "", false)
+}
+
+// Returning returns a RETURNING clause listing the same columns as Wildcard, such as:
+//
+//	RETURNING "id","created_at"
+//
+// If there are no columns, an empty string is returned, so you can append it conditionally.
+func Returning(v interface{}) string {
+	return returning(fields(v, structref.DefaultTagKey))
+}
+
+// ReturningFields returns a RETURNING clause for the given columns, quoted like Wildcard.
+// If no column is given, an empty string is returned.
+func ReturningFields(fields ...string) string {
+	return returning(fields)
+}
+
+func returning(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return "RETURNING " + wildcard(columns, nil, "", false)
+}
+
+// WildcardWithSeparator returns an expression like Wildcard, joining the column of a nested struct
+// and the columns of its fields with sep instead of a dot, so `db:"address"` and `db:"city"`
+// map to address__city with "__", such as when your column names contain dots for other reasons.
+// If sep is empty, a dot is used.
+//
+// Columns containing a dot are still aliased to themselves, so the alias always matches the selected column.
+func WildcardWithSeparator(v interface{}, sep string) string {
+	rv := typeOf(v)
+	if rv == nil {
+		return ""
+	}
+	if sep == "." {
+		// Share the cache entry of Wildcard.
+		sep = ""
+	}
+	info := cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey, separator: sep})
+	return wildcard(info.all.names, info.defaults, "", false)
+}
+
+// WildcardLower returns an expression like Wildcard, with each column name lowercased before quoting,
+// so it matches the columns of a table created with unquoted identifiers, which PostgreSQL folds to lowercase.
+// For example, a field tagged `db:"UserID"` is selected as "userid".
+//
+// Columns that only differ by case are selected twice, so use Lint to catch them.
+func WildcardLower(v interface{}) string {
+	info := typeInfoOf(typeOf(v), structref.DefaultTagKey)
+	elems := make([]string, len(info.all.names))
+	var defaults map[string]string
+	for i, c := range info.all.names {
+		elems[i] = strings.ToLower(c)
+		if def, ok := info.defaults[c]; ok {
+			if defaults == nil {
+				defaults = map[string]string{}
+			}
+			defaults[elems[i]] = def
+		}
+	}
+	return wildcard(elems, defaults, "", false)
+}
+
+// WildcardWithTag returns an expression like Wildcard, reading column names
+// from the given struct tag key instead of "db".
+func WildcardWithTag(v interface{}, tagKey string) string {
+	return wildcardOf(typeOf(v), tagKey, "", false)
+}
+
+// WildcardWithNamer returns an expression like Wildcard, using the columns returned by FieldsWithNamer.
+func WildcardWithNamer(v interface{}, namer func(string) string) string {
+	rv := typeOf(v)
+	if rv == nil {
+		return ""
+	}
+	info := newTypeInfo(rv, structref.Options{Namer: namer})
+	return wildcard(info.all.names, info.defaults, "", false)
+}
+
+// WildcardOf returns the same expression as Wildcard for the type T,
+// without requiring a value of the type.
+//
+//	sql := "SELECT " + pgtools.WildcardOf[User]() + " WHERE id = $1"
+func WildcardOf[T any]() string {
+	return wildcardOf(typeFor[T](), structref.DefaultTagKey, "", false)
+}
+
+// StaticWildcard returns the same expression as Wildcard for a list of columns, such as column names
+// generated at build time from Fields, so it doesn't need reflection:
+//
+//	var userColumns = []string{"username", "full_name", "email", "id", "theme"} // Generated.
+//
+//	sql := "SELECT " + pgtools.StaticWildcard(userColumns) + ` FROM "user" WHERE id = $1`
+//
+// Columns are quoted and aliased exactly like Wildcard does, so the SQL is byte-identical for the same columns.
+// The default tag option isn't known from the column names, so columns aren't wrapped in COALESCE.
+// An empty string is returned for an empty list.
+func StaticWildcard(cols []string) string {
+	return wildcard(cols, nil, "", false)
+}
+
+// wildcardOf returns the wildcard of all columns of a struct type.
+func wildcardOf(rv reflect.Type, tagKey string, qualifier string, aliasAll bool) string {
+	info := typeInfoOf(rv, tagKey)
+	return wildcard(info.all.names, info.defaults, qualifier, aliasAll)
+}
+
+// wildcard quotes the columns, aliasing the ones containing a dot.
+// Columns with a default are wrapped in COALESCE, and aliased.
+// If qualifier is set, each column is prefixed with it, so it must already be quoted.
+// If aliasAll is set, every column is aliased.
+func wildcard(elems []string, defaults map[string]string, qualifier string, aliasAll bool) string {
 	// Logic below based on strings.Join, but avoids column ambiguity.
 	if len(elems) == 0 {
 		return ""
@@ -49,99 +233,406 @@ func Wildcard(v interface{}) string {
 		if n != 0 {
 			b.WriteString(`,`)
 		}
-		b.WriteString(`"`)
-		b.WriteString(s)
-		b.WriteString(`"`)
+		def, hasDefault := defaults[s]
+		if hasDefault {
+			b.WriteString(`COALESCE(`)
+		}
+		if qualifier != "" {
+			b.WriteString(qualifier)
+			b.WriteString(`.`)
+		}
+		writeIdentifier(&b, s)
+		if hasDefault {
+			b.WriteString(`, `)
+			b.WriteString(def)
+			b.WriteString(`)`)
+		}
 		// Alias any field containing a dot to avoid output column ambiguity,
 		// as required by scany to handle nested structs.
-		if strings.ContainsRune(s, '.') {
-			b.WriteString(` as "`)
-			b.WriteString(s)
-			b.WriteString(`"`)
+		if aliasAll || hasDefault || strings.ContainsRune(s, '.') {
+			b.WriteString(` as `)
+			writeIdentifier(&b, s)
 		}
 	}
 	return b.String()
 }
 
-// lru is the least recently used caching for the Fields function.
-type lru struct {
-	cap int // Capacity.
-
-	mu sync.Mutex // guards following
-	m  map[reflect.Type]*list.Element
-	l  *list.List
-}
-
-var wildcardsCache = &lru{
-	cap: 1000, // Likely high enough for most applications, but low enough to mitigate a memory leak.
-
-	m: map[reflect.Type]*list.Element{},
-	l: list.New(),
+// contains reports whether the column is in the list.
+func contains(list []string, column string) bool {
+	for _, c := range list {
+		if c == column {
+			return true
+		}
+	}
+	return false
 }
 
 // Fields returns column names for a SQL table that can be queried by a given Go struct.
 // Only use this function to list fields on a struct.
+// Fields tagged with `db:"-"` are skipped entirely, including nested and embedded structs.
+// The columns of embedded structs are listed as if they were fields of the struct,
+// whether the struct or a pointer to it is embedded.
+//
+// The "db" key in the struct field's tag accepts the following options after the column name:
+//
+//   - json: the field maps to a single JSON or JSONB column, whether its type is a struct,
+//     a pointer to a struct, a slice, or a map, so nested structs aren't flattened.
+//   - readonly: the column is listed by Fields, but omitted by helpers writing data,
+//     such as Insert and UpdateSet. Use it for columns set by the database, like a serial id.
+//   - generated: the column is a generated column, as in GENERATED ALWAYS AS (...) STORED,
+//     which can be selected but not written, so it behaves like readonly, and is reported by FieldInfos.
+//   - prefix: the columns of a nested or embedded struct are flattened and prefixed with the column name
+//     without a separator, so `db:"address_,prefix"` maps to address_street and address_city
+//     instead of address.street and address.city.
+//   - pk: the column is part of the primary key, as used by WhereByPK.
+//   - default: Wildcard replaces NULL with the given SQL expression, so `db:"count,default=0"`
+//     selects COALESCE("count", 0) as "count". Use it to scan a nullable column into a non-pointer field.
+//     The expression cannot contain a comma.
+//
+// Options can be combined, as in `db:"meta,json,readonly"`.
+//
+// Slices and arrays, such as []string, []int, and []byte, map to a single column, such as a text[],
+// int[], or bytea column, and so do slices of structs, whose fields aren't listed.
+// The json option doesn't change the columns of a slice or a map, but marks them as JSON for FieldInfos,
+// so use it when a slice of structs is stored in a JSON or JSONB column rather than an array column.
+//
+// Struct types such as time.Time, sql.NullString, pgtype types, and other types implementing
+// sql.Scanner or driver.Valuer map to a single column, so their fields aren't listed.
+// Use RegisterScalar to register other struct types as single columns.
+// Other named types, such as type Status int, are single columns already;
+// use RegisterScalarValue to change the value written to their column, such as to store an enum as text.
 //
 // To avoid ambiguity issues, it's important to use the Wildcard function instead of
 // calling strings.Join(pgtools.Field(v), ", ") to generate the query expression.
+//
+// The returned slice is a copy, so it's safe to modify it.
 func Fields(v interface{}) []string {
-	// Get the right type.
-	if v == nil {
+	return FieldsWithTag(v, structref.DefaultTagKey)
+}
+
+// FieldsError returns column names like Fields, but returns an error if v is nil,
+// isn't a struct or a pointer to a struct, or has no columns.
+//
+// Wildcard and Fields don't return an error to make usage simpler in the happy path,
+// but you can use FieldsError to validate your types fail fast, such as during startup.
+func FieldsError(v interface{}) ([]string, error) {
+	rv := typeOf(v)
+	switch {
+	case rv == nil:
+		return nil, errors.New("pgtools: cannot get fields of nil")
+	case rv.Kind() != reflect.Struct:
+		return nil, fmt.Errorf("pgtools: cannot get fields of %v: not a struct or a pointer to a struct", reflect.TypeOf(v))
+	}
+	columns := Fields(v)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("pgtools: %v has no columns", rv)
+	}
+	return columns, nil
+}
+
+// FieldsWithTag returns column names like Fields, reading them from the given
+// struct tag key instead of "db".
+// This is useful if your structs are already tagged for another library, as in `sql:"name"`.
+func FieldsWithTag(v interface{}, tagKey string) []string {
+	return copyColumns(fields(v, tagKey))
+}
+
+// FieldCount returns the number of columns of v, as returned by Fields, including readonly columns.
+// Unlike len(Fields(v)), it doesn't copy the columns.
+// The rows passed to CopyFrom omit readonly columns, so check their length against len(CopyColumns(v)) instead.
+func FieldCount(v interface{}) int {
+	return len(fields(v, structref.DefaultTagKey))
+}
+
+// fields returns the columns of v read using the given struct tag key.
+// The returned slice is shared by the cache, and must not be modified.
+func fields(v interface{}, tagKey string) []string {
+	return fieldsOfType(typeOf(v), tagKey)
+}
+
+// fieldsOfType is like fields, but for a type.
+func fieldsOfType(rv reflect.Type, tagKey string) []string {
+	if rv == nil {
 		return nil
 	}
-	var rv reflect.Type
-	if reflect.TypeOf(v).Kind() == reflect.Ptr {
-		rv = reflect.TypeOf(v).Elem()
-	} else {
-		rv = reflect.Indirect(reflect.ValueOf(v)).Type()
+	return cachedFields(cacheKey{t: rv, tagKey: tagKey})
+}
+
+// typeInfoOf returns what is known about the struct type using the cache.
+// If rv is nil, an empty typeInfo is returned.
+func typeInfoOf(rv reflect.Type, tagKey string) *typeInfo {
+	if rv == nil {
+		return &typeInfo{}
+	}
+	return cachedTypeInfo(cacheKey{t: rv, tagKey: tagKey})
+}
+
+// copyColumns returns a copy of the columns, so callers can't modify the cached ones.
+func copyColumns(columns []string) []string {
+	if columns == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(columns)), columns...)
+}
+
+// FieldsWithNamer returns column names like Fields, but uses namer to convert
+// the name of a Go field without an explicit column name in its "db" tag to a column name,
+// instead of converting it to snake_case.
+// Explicit column names in the "db" tag always take precedence over the namer.
+//
+// Unlike Fields, the result isn't cached, as functions can't be compared.
+func FieldsWithNamer(v interface{}, namer func(string) string) []string {
+	rv := typeOf(v)
+	if rv == nil {
+		return nil
+	}
+	return newTypeInfo(rv, structref.Options{Namer: namer}).all.names
+}
+
+// ColumnIndexMap returns the columns of v like Fields, mapped to the index of their struct field.
+// An index is the path to the field as used by reflect.Value.FieldByIndex,
+// such as [2 0] for the first field of a struct nested as the third field of v.
+//
+// It is useful to write your own row scanner without reimplementing how columns are read from struct tags.
+// The returned map is a copy, so it's safe to modify it.
+func ColumnIndexMap(v interface{}) map[string][]int {
+	rv := typeOf(v)
+	if rv == nil {
+		return nil
+	}
+	all := cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).all
+	m := make(map[string][]int, len(all.names))
+	for i, name := range all.names {
+		m[name] = append([]int(nil), all.fields[i].Index...)
+	}
+	return m
+}
+
+// FieldInfo describes a column of a struct, and the field it's mapped from.
+type FieldInfo struct {
+	// Column name, as returned by Fields.
+	Column string
+
+	// GoType of the struct field, such as string, *time.Time, or sql.NullString.
+	GoType reflect.Type
+
+	// IsJSON is set when the field has the "json" tag option.
+	IsJSON bool
+
+	// IsGenerated is set when the field has the "generated" tag option.
+	IsGenerated bool
+
+	// IsPointer is set when GoType is a pointer, which is how a nullable column is usually mapped,
+	// along with types such as sql.NullString.
+	IsPointer bool
+
+	// Index of the struct field, as used by reflect.Value.FieldByIndex.
+	Index []int
+}
+
+// FieldPairs returns the columns of v like Fields, each with the path of the Go struct field it's mapped from,
+// such as Address.City for the column address.city, to debug a surprising mapping.
+// The path of a promoted field includes the name of the embedded struct, such as Base.ID.
+//
+// The returned slice is a copy, so it's safe to modify it.
+func FieldPairs(v interface{}) []struct{ Column, Field string } {
+	rv := typeOf(v)
+	if rv == nil {
+		return nil
+	}
+	pairs := cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).columnFieldPairs()
+	if pairs == nil {
+		return nil
+	}
+	return append([]struct{ Column, Field string }(nil), pairs...)
+}
+
+// Schema returns a hash of the columns of v, in the same order as Fields, and of the Go types of their fields,
+// as a hex-encoded SHA-256 digest, such as to detect the shape of a struct changed since a migration was written,
+// or to name prepared statements.
+//
+// It's deterministic, and changes when a column is added, removed, renamed, or reordered,
+// or when the type of its field changes. Tag options such as readonly don't change it.
+// Types are identified by their package path and name, so moving a type to another package changes it too.
+// An empty string is returned if v has no columns.
+func Schema(v interface{}) string {
+	rv := typeOf(v)
+	if rv == nil {
+		return ""
+	}
+	return cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).columnsSchema()
+}
+
+// schemaHash returns the hash of the columns and the types of their fields returned by Schema.
+func schemaHash(infos []FieldInfo) string {
+	if len(infos) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, info := range infos {
+		fmt.Fprintf(h, "%s\x00%s\x00", info.Column, typeName(info.GoType))
 	}
-	wildcardsCache.mu.Lock()
-	defer wildcardsCache.mu.Unlock()
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// typeName returns the name of t qualified by the full path of its package, such as *github.com/jackc/pgtype.Text,
+// as reflect.Type.String only uses the last element of the path.
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		return t.PkgPath() + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeName(t.Elem()))
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	}
+	// Unnamed types such as anonymous structs and funcs.
+	return t.String()
+}
+
+// FieldInfos returns the columns of v like Fields, with the struct field they're mapped from,
+// such as to generate code or to check nullable columns are mapped to a pointer or a Null type.
+//
+// The returned slice is a copy, so it's safe to modify it.
+func FieldInfos(v interface{}) []FieldInfo {
+	rv := typeOf(v)
+	if rv == nil {
+		return nil
+	}
+	infos := cachedTypeInfo(cacheKey{t: rv, tagKey: structref.DefaultTagKey}).fieldInfos
+	if infos == nil {
+		return nil
+	}
+	c := make([]FieldInfo, len(infos))
+	for i, info := range infos {
+		info.Index = append([]int(nil), info.Index...)
+		c[i] = info
+	}
+	return c
+}
 
-	// field exists to maintain a reference to the struct in the linked list.
-	type field struct {
-		t reflect.Type
-		v []string
+// typeOf returns the type of v, or the type it points to.
+// If v is nil, nil is returned.
+func typeOf(v interface{}) reflect.Type {
+	if v == nil {
+		return nil
 	}
-	// Keep the map and linked list of the LRU cache up-to-date.
-	if cache, ok := wildcardsCache.m[rv]; ok {
-		wildcardsCache.l.MoveToFront(cache)
-		return cache.Value.(field).v
+	if reflect.TypeOf(v).Kind() == reflect.Ptr {
+		return reflect.TypeOf(v).Elem()
 	}
+	return reflect.Indirect(reflect.ValueOf(v)).Type()
+}
+
+// FieldsOf returns the same column names as Fields for the type T,
+// without requiring a value of the type.
+func FieldsOf[T any]() []string {
+	return copyColumns(fieldsOfType(typeFor[T](), structref.DefaultTagKey))
+}
 
-	// If we don't have the data cached yet, continue.
-	if wildcardsCache.l.Len() == wildcardsCache.cap {
-		oldest := wildcardsCache.l.Back()
-		wildcardsCache.l.Remove(oldest)
-		delete(wildcardsCache.m, oldest.Value.(field).t)
+// typeFor returns the type T, or the type it points to.
+func typeFor[T any]() reflect.Type {
+	rv := reflect.TypeOf((*T)(nil)).Elem()
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
 	}
+	return rv
+}
+
+// typeInfo holds the columns of a struct type, and where they are mapped.
+type typeInfo struct {
+	// all columns, as used by SELECT queries.
+	all columnSet
 
-	// Get the columns, cache, and return it.
-	columns := fields(rv)
-	wildcardsCache.m[rv] = wildcardsCache.l.PushFront(field{
-		t: rv,
-		v: columns,
+	// writable columns, as used by INSERT and UPDATE statements.
+	writable columnSet
+
+	// defaults of the columns with the "default" tag option, or nil if there are none.
+	defaults map[string]string
+
+	// primaryKeys columns, with the "pk" tag option, in the same order as all.
+	primaryKeys []string
+
+	// fieldInfos of all columns, in the same order as all.
+	fieldInfos []FieldInfo
+
+	// rt is the struct type, or nil for an empty typeInfo.
+	rt reflect.Type
+
+	// schema hash of the columns, as returned by Schema.
+	// It's only computed on first use, as most types are never hashed.
+	schemaOnce sync.Once
+	schema     string
+
+	// fieldPairs of all columns and the paths of their fields, in the same order as all.
+	// They're only computed on first use, like schema.
+	fieldPairsOnce sync.Once
+	fieldPairs     []struct{ Column, Field string }
+}
+
+// columnsSchema returns the schema hash of the columns, computing it on first use.
+func (info *typeInfo) columnsSchema() string {
+	info.schemaOnce.Do(func() {
+		info.schema = schemaHash(info.fieldInfos)
+	})
+	return info.schema
+}
+
+// columnFieldPairs returns the columns and the paths of their fields, computing them on first use.
+func (info *typeInfo) columnFieldPairs() []struct{ Column, Field string } {
+	info.fieldPairsOnce.Do(func() {
+		for _, fi := range info.fieldInfos {
+			info.fieldPairs = append(info.fieldPairs, struct{ Column, Field string }{
+				Column: fi.Column,
+				Field:  fieldPath(info.rt, fi.Index),
+			})
+		}
 	})
-	return columns
+	return info.fieldPairs
+}
+
+// columnSet is a list of columns, and the struct fields they are mapped from.
+type columnSet struct {
+	names  []string
+	fields []structref.Column
+	values []func(interface{}) interface{} // Converters registered with RegisterScalarValue, or nil.
+	index  map[string]int                  // Position of each column in names and fields.
+}
+
+func (cs *columnSet) add(name string, field structref.Column, value func(interface{}) interface{}) {
+	if cs.index == nil {
+		cs.index = map[string]int{}
+	}
+	cs.index[name] = len(cs.names)
+	cs.names = append(cs.names, name)
+	cs.fields = append(cs.fields, field)
+	cs.values = append(cs.values, value)
 }
 
-func fields(rv reflect.Type) []string {
+func newTypeInfo(rv reflect.Type, opts structref.Options) *typeInfo {
 	// Column is used to make it possible to sort the columns by index.
 	type column struct {
-		indices []int
-		name    string
+		field structref.Column
+		name  string
 	}
 
 	var cs []column
-	for name, i := range structref.GetColumnToFieldIndexMap(rv) {
+	for name, field := range structref.GetColumns(rv, opts) {
 		cs = append(cs, column{
-			indices: i,
-			name:    name,
+			field: field,
+			name:  name,
 		})
 	}
 	// Make fields output stable with respect to the struct fields in order.
 	sort.SliceStable(cs, func(i, j int) bool {
-		a, b := cs[i].indices, cs[j].indices
+		a, b := cs[i].field.Index, cs[j].field.Index
 		// Go inwards each nested field until the end:
 		// indices a and b represent the path to the left and right fields being sorted.
 		for {
@@ -159,9 +650,31 @@ func fields(rv reflect.Type) []string {
 		}
 	})
 
-	var columns []string
+	info := &typeInfo{rt: rv}
 	for _, column := range cs {
-		columns = append(columns, column.name)
+		if column.field.Default != "" {
+			if info.defaults == nil {
+				info.defaults = map[string]string{}
+			}
+			info.defaults[column.name] = column.field.Default
+		}
+		if column.field.PrimaryKey {
+			info.primaryKeys = append(info.primaryKeys, column.name)
+		}
+		goType := rv.FieldByIndex(column.field.Index).Type
+		value := scalarValueFunc(goType)
+		info.all.add(column.name, column.field, value)
+		info.fieldInfos = append(info.fieldInfos, FieldInfo{
+			Column:      column.name,
+			GoType:      goType,
+			IsJSON:      column.field.JSON,
+			IsGenerated: column.field.Generated,
+			IsPointer:   goType.Kind() == reflect.Ptr,
+			Index:       column.field.Index,
+		})
+		if !column.field.ReadOnly {
+			info.writable.add(column.name, column.field, value)
+		}
 	}
-	return columns
+	return info
 }
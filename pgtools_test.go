@@ -1,12 +1,15 @@
 package pgtools_test
 
 import (
+	"database/sql"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgtype"
 	"github.com/partounian/pgtools"
 )
 
@@ -46,6 +49,20 @@ func ExampleFields() {
 	// theme
 }
 
+func ExampleWildcardOf() {
+	sql := "SELECT " + pgtools.WildcardOf[User]() + " WHERE id = $1"
+	fmt.Println(sql)
+	// Output:
+	// SELECT "username","full_name","email","id","theme" WHERE id = $1
+}
+
+func ExampleWildcardWithAlias() {
+	sql := "SELECT " + pgtools.WildcardWithAlias(User{}, "u") + ` FROM "user" u JOIN team t ON t.id = u.team_id`
+	fmt.Println(sql)
+	// Output:
+	// SELECT "u"."username" as "username","u"."full_name" as "full_name","u"."email" as "email","u"."id" as "id","u"."theme" as "theme" FROM "user" u JOIN team t ON t.id = u.team_id
+}
+
 type mock struct {
 	Automatic string
 	Tagged    string `db:"tagged"`
@@ -265,6 +282,17 @@ func TestWildcard(t *testing.T) {
 			desc: "RegularFieldWithDots",
 			want: `"regular.field.with.dots" as "regular.field.with.dots"`,
 		},
+		{
+			v: struct {
+				Before  string
+				Ignored string `db:"-"`
+				Theme   Theme  `db:"-"`
+				mock    `db:"-"`
+				After   string
+			}{},
+			desc: "ignored",
+			want: `"before","after"`,
+		},
 		{
 			v:    themeImplicit{},
 			desc: "implicit",
@@ -280,6 +308,733 @@ func TestWildcard(t *testing.T) {
 	}
 }
 
+func TestWildcardOf(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc   string
+		want   interface{}
+		fields []string
+		got    string
+	}{
+		{desc: "empty", want: emptyEmbed{}, fields: pgtools.FieldsOf[emptyEmbed](), got: pgtools.WildcardOf[emptyEmbed]()},
+		{desc: "mock", want: mock{}, fields: pgtools.FieldsOf[mock](), got: pgtools.WildcardOf[mock]()},
+		{desc: "pointer", want: &mock{}, fields: pgtools.FieldsOf[*mock](), got: pgtools.WildcardOf[*mock]()},
+		{desc: "multiembed", want: mockMultiEmbed{}, fields: pgtools.FieldsOf[mockMultiEmbed](), got: pgtools.WildcardOf[mockMultiEmbed]()},
+		{desc: "json", want: jsonMock{}, fields: pgtools.FieldsOf[jsonMock](), got: pgtools.WildcardOf[jsonMock]()},
+		{desc: "HasNestedMock", want: HasNestedMock{}, fields: pgtools.FieldsOf[HasNestedMock](), got: pgtools.WildcardOf[HasNestedMock]()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if want := pgtools.Wildcard(tc.want); want != tc.got {
+				t.Errorf("expected expression to be %v, got %v instead", want, tc.got)
+			}
+			if want := pgtools.Fields(tc.want); !reflect.DeepEqual(want, tc.fields) {
+				t.Errorf("expected fields to be %v, got %v instead", want, tc.fields)
+			}
+		})
+	}
+}
+
+func TestWildcardWithAlias(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v     interface{}
+		alias string
+		desc  string
+		want  string
+	}{
+		{
+			v:     emptyEmbed{},
+			alias: "e",
+			desc:  "empty",
+		},
+		{
+			v:     nil,
+			alias: "e",
+			desc:  "nil",
+		},
+		{
+			v:     &mock{},
+			alias: "m",
+			desc:  "mock",
+			want:  `"m"."automatic" as "automatic","m"."tagged" as "tagged","m"."one_two" as "one_two","m"."CamelCase" as "CamelCase"`,
+		},
+		{
+			v:     &mock{},
+			alias: "m.x",
+			desc:  "dot",
+			want:  `"m.x"."automatic" as "automatic","m.x"."tagged" as "tagged","m.x"."one_two" as "one_two","m.x"."CamelCase" as "CamelCase"`,
+		},
+		{
+			v:     &HasNestedMock{},
+			alias: "n",
+			desc:  "HasNestedMock",
+			want:  `"n"."id" as "id","n"."name" as "name","n"."code" as "code","n"."is_active" as "is_active","n"."theme.primary_color" as "theme.primary_color","n"."theme.secondary_color" as "theme.secondary_color","n"."theme.text_color" as "theme.text_color","n"."theme.text_uppercase" as "theme.text_uppercase","n"."theme.font_family_headings" as "theme.font_family_headings","n"."theme.font_family_body" as "theme.font_family_body","n"."theme.font_family_default" as "theme.font_family_default","n"."theme" as "theme","n"."created_at" as "created_at","n"."modified_at" as "modified_at"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.WildcardWithAlias(tc.v, tc.alias); tc.want != got {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldsError(t *testing.T) {
+	t.Parallel()
+	var number int
+	testCases := []struct {
+		v       interface{}
+		desc    string
+		want    []string
+		wantErr string
+	}{
+		{
+			v:       nil,
+			desc:    "nil",
+			wantErr: "pgtools: cannot get fields of nil",
+		},
+		{
+			v:       1,
+			desc:    "int",
+			wantErr: "pgtools: cannot get fields of int: not a struct or a pointer to a struct",
+		},
+		{
+			v:       &number,
+			desc:    "int pointer",
+			wantErr: "pgtools: cannot get fields of *int: not a struct or a pointer to a struct",
+		},
+		{
+			v:       []mock{},
+			desc:    "slice",
+			wantErr: "pgtools: cannot get fields of []pgtools_test.mock: not a struct or a pointer to a struct",
+		},
+		{
+			v:       emptyEmbed{},
+			desc:    "empty",
+			wantErr: "pgtools: pgtools_test.emptyEmbed has no columns",
+		},
+		{
+			v: &struct {
+				unexported int
+			}{},
+			desc:    "unexported",
+			wantErr: "pgtools: struct { unexported int } has no columns",
+		},
+		{
+			v:    &mock{},
+			desc: "mock",
+			want: []string{"automatic", "tagged", "one_two", "CamelCase"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := pgtools.FieldsError(tc.v)
+			if err == nil && tc.wantErr != "" || err != nil && err.Error() != tc.wantErr {
+				t.Errorf("expected error to be %q, got %v instead", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected fields to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWildcardWithTable(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v     interface{}
+		table string
+		desc  string
+		want  string
+	}{
+		{
+			v:     nil,
+			table: "users",
+			desc:  "nil",
+		},
+		{
+			v:     &mock{},
+			table: "users",
+			desc:  "mock",
+			want:  `"users"."automatic","users"."tagged","users"."one_two","users"."CamelCase"`,
+		},
+		{
+			v:     &customer{},
+			table: "customers",
+			desc:  "nested",
+			want:  `"customers"."name","customers"."address.street" as "address.street","customers"."address.city" as "address.city","customers"."address"`,
+		},
+		{
+			v:     &mock{},
+			table: "auth.users",
+			desc:  "schema",
+			want:  `"auth"."users"."automatic","auth"."users"."tagged","auth"."users"."one_two","auth"."users"."CamelCase"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.WildcardWithTable(tc.v, tc.table); tc.want != got {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWildcardExcept(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v       interface{}
+		exclude []string
+		desc    string
+		want    string
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    &mock{},
+			desc: "none",
+			want: `"automatic","tagged","one_two","CamelCase"`,
+		},
+		{
+			v:       &mock{},
+			exclude: []string{"tagged", "CamelCase"},
+			desc:    "mock",
+			want:    `"automatic","one_two"`,
+		},
+		{
+			v:       &mock{},
+			exclude: []string{"Tagged", "camel_case", "missing"},
+			desc:    "unknown",
+			want:    `"automatic","tagged","one_two","CamelCase"`,
+		},
+		{
+			v:       &mock{},
+			exclude: []string{"automatic", "tagged", "one_two", "CamelCase"},
+			desc:    "all",
+		},
+		{
+			v:       &HasNestedMock{},
+			exclude: []string{"id", "theme.primary_color", "theme.secondary_color", "theme.text_color", "theme.text_uppercase", "theme.font_family_headings", "theme.font_family_body"},
+			desc:    "HasNestedMock",
+			want:    `"name","code","is_active","theme.font_family_default" as "theme.font_family_default","theme","created_at","modified_at"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.WildcardExcept(tc.v, tc.exclude...); tc.want != got {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+	// Check the cached fields weren't modified.
+	if want, got := `"automatic","tagged","one_two","CamelCase"`, pgtools.Wildcard(mock{}); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+}
+
+func TestWildcardOnly(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v       interface{}
+		include []string
+		desc    string
+		want    string
+	}{
+		{
+			v:       nil,
+			include: []string{"id"},
+			desc:    "nil",
+		},
+		{
+			v:    &mock{},
+			desc: "none",
+		},
+		{
+			v:       &mock{},
+			include: []string{"CamelCase", "automatic"},
+			desc:    "mock",
+			want:    `"automatic","CamelCase"`,
+		},
+		{
+			v:       &mock{},
+			include: []string{"Tagged", "camel_case", "missing", "one_two"},
+			desc:    "unknown",
+			want:    `"one_two"`,
+		},
+		{
+			v:       &HasNestedMock{},
+			include: []string{"theme.text_color", "id", "modified_at"},
+			desc:    "HasNestedMock",
+			want:    `"id","theme.text_color" as "theme.text_color","modified_at"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.WildcardOnly(tc.v, tc.include...); tc.want != got {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestColumnIndexMap(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want map[string][]int
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+			want: map[string][]int{},
+		},
+		{
+			v:    &mock{},
+			desc: "mock",
+			want: map[string][]int{
+				"automatic": {0},
+				"tagged":    {1},
+				"one_two":   {2},
+				"CamelCase": {3},
+			},
+		},
+		{
+			v:    customer{},
+			desc: "nested",
+			want: map[string][]int{
+				"name":           {0},
+				"address":        {1},
+				"address.street": {1, 0},
+				"address.city":   {1, 1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			got := pgtools.ColumnIndexMap(tc.v)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected map to be %v, got %v instead", tc.want, got)
+			}
+			// Check modifying the returned map doesn't change the cached columns.
+			for k := range got {
+				got[k][0] = -1
+				delete(got, k)
+			}
+			if got := pgtools.ColumnIndexMap(tc.v); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected map to be %v after modification, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldCount(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want int
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+		},
+		{
+			v:    &mock{},
+			desc: "mock",
+			want: 4,
+		},
+		{
+			v:    customer{},
+			desc: "nested",
+			want: len(pgtools.Fields(customer{})),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.FieldCount(tc.v); got != tc.want {
+				t.Errorf("expected field count to be %d, got %d instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldCountAllocs(t *testing.T) {
+	v := &mock{}
+	pgtools.FieldCount(v) // Warm the cache.
+	if allocs := testing.AllocsPerRun(100, func() { pgtools.FieldCount(v) }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v instead", allocs)
+	}
+}
+
+func TestFieldInfos(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City string
+	}
+	type profile struct {
+		ID       int
+		Nickname *string
+		Tags     []string `db:"tags,json"`
+		Address  *address
+		Created  time.Time
+		Slug     string `db:"slug,generated"`
+	}
+	want := []pgtools.FieldInfo{
+		{Column: "id", GoType: reflect.TypeOf(0), Index: []int{0}},
+		{Column: "nickname", GoType: reflect.TypeOf((*string)(nil)), IsPointer: true, Index: []int{1}},
+		{Column: "tags", GoType: reflect.TypeOf([]string{}), IsJSON: true, Index: []int{2}},
+		{Column: "address.city", GoType: reflect.TypeOf(""), Index: []int{3, 0}},
+		{Column: "address", GoType: reflect.TypeOf((*address)(nil)), IsPointer: true, Index: []int{3}},
+		{Column: "created", GoType: reflect.TypeOf(time.Time{}), Index: []int{4}},
+		{Column: "slug", GoType: reflect.TypeOf(""), IsGenerated: true, Index: []int{5}},
+	}
+	got := pgtools.FieldInfos(&profile{})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected field infos to be %v, got %v instead", want, got)
+	}
+	// Check modifying the returned slice doesn't change the cached columns.
+	got[0].Column = "changed"
+	got[3].Index[0] = -1
+	if got := pgtools.FieldInfos(profile{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected field infos to be %v after modification, got %v instead", want, got)
+	}
+	if got := pgtools.FieldInfos(nil); got != nil {
+		t.Errorf("expected field infos of nil to be nil, got %v instead", got)
+	}
+}
+
+func TestFieldPairs(t *testing.T) {
+	t.Parallel()
+	type base struct {
+		ID int64
+	}
+	type account struct {
+		base
+		Name    string `db:"full_name"`
+		Address *address
+		Ignored string `db:"-"`
+	}
+	want := []struct{ Column, Field string }{
+		{Column: "id", Field: "base.ID"},
+		{Column: "full_name", Field: "Name"},
+		{Column: "address.street", Field: "Address.Street"},
+		{Column: "address.city", Field: "Address.City"},
+		{Column: "address", Field: "Address"},
+	}
+	got := pgtools.FieldPairs(&account{})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected field pairs to be %v, got %v instead", want, got)
+	}
+	// Check modifying the returned slice doesn't change the cached pairs.
+	got[0].Field = "changed"
+	if got := pgtools.FieldPairs(account{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected field pairs to be %v after modification, got %v instead", want, got)
+	}
+	if got := pgtools.FieldPairs(nil); got != nil {
+		t.Errorf("expected field pairs of nil to be nil, got %v instead", got)
+	}
+	if got := pgtools.FieldPairs(emptyEmbed{}); got != nil {
+		t.Errorf("expected field pairs of empty struct to be nil, got %v instead", got)
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	t.Parallel()
+	type person struct {
+		FirstName string
+		FullName  string `db:"full_name,generated"`
+		LastName  string
+	}
+	p := person{FirstName: "Ada", FullName: "Ada Lovelace", LastName: "Lovelace"}
+	if want, got := `"first_name","full_name","last_name"`, pgtools.Wildcard(p); want != got {
+		t.Errorf("expected wildcard to be %v, got %v instead", want, got)
+	}
+	if want, got := `INSERT INTO "person" ("first_name","last_name") VALUES ($1, $2)`, pgtools.Insert("person", p); want != got {
+		t.Errorf("expected insert to be %v, got %v instead", want, got)
+	}
+	if want, got := `"first_name"=$1,"last_name"=$2`, pgtools.UpdateSet(p, 1); want != got {
+		t.Errorf("expected update to be %v, got %v instead", want, got)
+	}
+	if want, got := []interface{}{"Ada", "Lovelace"}, pgtools.Args(p); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected arguments to be %v, got %v instead", want, got)
+	}
+}
+
+func TestFieldsJSON(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name  string
+		Price int
+	}
+	type payload struct {
+		ID       string
+		Struct   item                   `db:"struct,json"`
+		Pointer  *item                  `db:"pointer,json"`
+		Slice    []item                 `db:"slice,json"`
+		Map      map[string]interface{} `db:"map,json"`
+		Embedded struct {
+			Theme Theme `db:"theme,json"`
+		}
+	}
+	want := []string{"id", "struct", "pointer", "slice", "map", "embedded.theme", "embedded"}
+	if got := pgtools.Fields(payload{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields to be %v, got %v instead", want, got)
+	}
+	if want, got := `"id","struct","pointer","slice","map","embedded.theme" as "embedded.theme","embedded"`, pgtools.Wildcard(payload{}); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+}
+
+func TestFieldsSlices(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name string
+	}
+	type record struct {
+		Tags    []string `db:"tags"`
+		Scores  []int
+		Data    []byte
+		Matrix  [][]int
+		Fixed   [2]int
+		Items   []item `db:"items,json"`
+		Pointer []*item
+	}
+	want := []string{"tags", "scores", "data", "matrix", "fixed", "items", "pointer"}
+	if got := pgtools.Fields(record{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields to be %v, got %v instead", want, got)
+	}
+	if want, got := `"tags","scores","data","matrix","fixed","items","pointer"`, pgtools.Wildcard(record{}); want != got {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+	for _, info := range pgtools.FieldInfos(record{}) {
+		if want := info.Column == "items"; info.IsJSON != want {
+			t.Errorf("expected IsJSON of %q to be %v, got %v instead", info.Column, want, info.IsJSON)
+		}
+	}
+}
+
+type Timestamps struct {
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+}
+
+func TestFieldsEmbeddedPointer(t *testing.T) {
+	t.Parallel()
+	type value struct {
+		ID string
+		Timestamps
+	}
+	type pointer struct {
+		ID string
+		*Timestamps
+	}
+	type tagged struct {
+		ID          string
+		*Timestamps `db:"ts"`
+	}
+	want := []string{"id", "created_at", "modified_at"}
+	if got := pgtools.Fields(value{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields of embedded struct to be %v, got %v instead", want, got)
+	}
+	if got := pgtools.Fields(pointer{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields of embedded pointer to be %v, got %v instead", want, got)
+	}
+	if want, got := []string{"id", "ts.created_at", "ts.modified_at"}, pgtools.Fields(&tagged{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields of tagged embedded pointer to be %v, got %v instead", want, got)
+	}
+	if want, got := []interface{}{"1", nil, nil}, pgtools.Args(pointer{ID: "1"}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected arguments of nil embedded pointer to be %v, got %v instead", want, got)
+	}
+}
+
+// money is a value type mapped to a single column with RegisterScalar.
+type money struct {
+	Amount   int64
+	Currency string
+}
+
+func TestFieldsScalar(t *testing.T) {
+	t.Parallel()
+	pgtools.RegisterScalar(reflect.TypeOf(&money{}))
+	type model struct {
+		ID        sql.NullInt64
+		Name      sql.NullString
+		Nickname  *sql.NullString
+		Text      pgtype.Text
+		Price     money
+		CreatedAt time.Time
+		DeletedAt *pgtype.Timestamptz
+	}
+	want := []string{"id", "name", "nickname", "text", "price", "created_at", "deleted_at"}
+	if got := pgtools.Fields(model{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields to be %v, got %v instead", want, got)
+	}
+}
+
+func TestWildcardWithNamer(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v     interface{}
+		namer func(string) string
+		desc  string
+		want  string
+	}{
+		{
+			v:     nil,
+			namer: strings.ToLower,
+			desc:  "nil",
+		},
+		{
+			v:     &mock{},
+			namer: strings.ToLower,
+			desc:  "lower",
+			want:  `"automatic","tagged","onetwo","CamelCase"`,
+		},
+		{
+			v:     mockEmbed{},
+			namer: strings.ToUpper,
+			desc:  "embed",
+			want:  `"BEFORE","AUTOMATIC","tagged","ONETWO","CamelCase","AFTER"`,
+		},
+		{
+			v: struct {
+				FirstName string
+				LastName  string `db:"surname"`
+			}{},
+			namer: func(s string) string { return "x_" + s },
+			desc:  "tagged wins",
+			want:  `"x_FirstName","surname"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.WildcardWithNamer(tc.v, tc.namer); tc.want != got {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+type defaultMock struct {
+	ID      string
+	Count   int     `db:"count,default=0"`
+	Title   string  `db:",default=''"`
+	Score   float64 `db:"score,readonly,default=-1"`
+	Address struct {
+		City string `db:"city,default='unknown'"`
+	}
+}
+
+func TestWildcardDefault(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		got  string
+		want string
+	}{
+		{
+			desc: "Wildcard",
+			got:  pgtools.Wildcard(defaultMock{}),
+			want: `"id",COALESCE("count", 0) as "count",COALESCE("title", '') as "title",COALESCE("score", -1) as "score",COALESCE("address.city", 'unknown') as "address.city","address"`,
+		},
+		{
+			desc: "WildcardWithAlias",
+			got:  pgtools.WildcardWithAlias(defaultMock{}, "d"),
+			want: `"d"."id" as "id",COALESCE("d"."count", 0) as "count",COALESCE("d"."title", '') as "title",COALESCE("d"."score", -1) as "score",COALESCE("d"."address.city", 'unknown') as "address.city","d"."address" as "address"`,
+		},
+		{
+			desc: "WildcardOnly",
+			got:  pgtools.WildcardOnly(defaultMock{}, "id", "count"),
+			want: `"id",COALESCE("count", 0) as "count"`,
+		},
+		{
+			desc: "Fields",
+			got:  strings.Join(pgtools.Fields(defaultMock{}), ","),
+			want: `id,count,title,score,address.city,address`,
+		},
+		{
+			desc: "Returning",
+			got:  pgtools.Returning(defaultMock{}),
+			want: `RETURNING "id","count","title","score","address.city" as "address.city","address"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if tc.got != tc.want {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, tc.got)
+			}
+		})
+	}
+}
+
+func TestWildcardWithSeparator(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		sep  string
+		want string
+	}{
+		{
+			desc: "default",
+			want: `"name","address.street" as "address.street","address.city" as "address.city","address"`,
+		},
+		{
+			desc: "dot",
+			sep:  ".",
+			want: `"name","address.street" as "address.street","address.city" as "address.city","address"`,
+		},
+		{
+			desc: "underscores",
+			sep:  "__",
+			want: `"name","address__street","address__city","address"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.WildcardWithSeparator(customer{}, tc.sep); got != tc.want {
+				t.Errorf("expected expression to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWildcardLower(t *testing.T) {
+	t.Parallel()
+	type account struct {
+		ID      string `db:"ID"`
+		OwnerID string `db:"OwnerID,default=''"`
+		Name    string
+	}
+	want := `"id",COALESCE("ownerid", '') as "ownerid","name"`
+	if got := pgtools.WildcardLower(account{}); got != want {
+		t.Errorf("expected expression to be %v, got %v instead", want, got)
+	}
+	if got, want := pgtools.Wildcard(account{}), `"ID",COALESCE("OwnerID", '') as "OwnerID","name"`; got != want {
+		t.Errorf("expected Wildcard to keep the case, got %v instead", got)
+	}
+}
+
 func BenchmarkWildcard(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		pgtools.Wildcard(mock{})
@@ -297,3 +1052,123 @@ func BenchmarkWildcardAsync(b *testing.B) {
 	}
 	w.Wait()
 }
+
+func BenchmarkWildcardParallel(b *testing.B) {
+	// Cache hits from many goroutines using the same type shouldn't contend for a lock.
+	pgtools.Wildcard(mock{})
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pgtools.Wildcard(mock{})
+		}
+	})
+}
+
+type quoteMock struct {
+	ID     string `db:"i\"d,pk"`
+	Order  string `db:"order"`
+	Nested struct {
+		Key string `db:"a\"b"`
+	} `db:"x\"y"`
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		got  string
+		want string
+	}{
+		{
+			desc: "Wildcard",
+			got:  pgtools.Wildcard(quoteMock{}),
+			want: `"i""d","order","x""y.a""b" as "x""y.a""b","x""y"`,
+		},
+		{
+			desc: "WildcardWithAlias",
+			got:  pgtools.WildcardWithAlias(quoteMock{}, `q"t`),
+			want: `"q""t"."i""d" as "i""d","q""t"."order" as "order","q""t"."x""y.a""b" as "x""y.a""b","q""t"."x""y" as "x""y"`,
+		},
+		{
+			desc: "Insert",
+			got:  pgtools.Insert(`ta"ble`, quoteMock{}),
+			want: `INSERT INTO "ta""ble" ("i""d","order","x""y.a""b","x""y") VALUES ($1, $2, $3, $4)`,
+		},
+		{
+			desc: "InsertColumnsMap",
+			got: func() string {
+				cols, _, _ := pgtools.InsertColumnsMap(map[string]interface{}{`a"; DROP TABLE users; --`: 1})
+				return cols
+			}(),
+			want: `"a""; DROP TABLE users; --"`,
+		},
+		{
+			desc: "UpdateSet",
+			got:  pgtools.UpdateSet(quoteMock{}, 1),
+			want: `"i""d"=$1,"order"=$2,"x""y.a""b"=$3,"x""y"=$4`,
+		},
+		{
+			desc: "OnConflictUpdate",
+			got:  pgtools.OnConflictUpdate(quoteMock{}, pgtools.PrimaryKeys(quoteMock{})),
+			want: `ON CONFLICT ("i""d") DO UPDATE SET "order"=EXCLUDED."order","x""y.a""b"=EXCLUDED."x""y.a""b","x""y"=EXCLUDED."x""y"`,
+		},
+		{
+			desc: "WhereByPK",
+			got:  pgtools.WhereByPK(quoteMock{}, 1),
+			want: `"i""d"=$1`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if tc.got != tc.want {
+				t.Errorf("expected SQL to be %v, got %v instead", tc.want, tc.got)
+			}
+		})
+	}
+}
+
+func ExampleStaticWildcard() {
+	columns := []string{"name", "address.street", "address.city"}
+	fmt.Println(pgtools.StaticWildcard(columns))
+	// Output:
+	// "name","address.street" as "address.street","address.city" as "address.city"
+}
+
+func TestStaticWildcard(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		v    interface{}
+	}{
+		{
+			desc: "user",
+			v:    User{},
+		},
+		{
+			desc: "nested",
+			v:    customer{},
+		},
+		{
+			desc: "embedded",
+			v:    mockMultiEmbed{},
+		},
+		{
+			desc: "quotes",
+			v:    quoteMock{},
+		},
+		{
+			desc: "empty",
+			v:    emptyEmbed{},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if want, got := pgtools.Wildcard(tc.v), pgtools.StaticWildcard(pgtools.Fields(tc.v)); want != got {
+				t.Errorf("expected static wildcard to be %v, got %v instead", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pgtools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// QueryBuilder composes a SELECT statement for a struct, listing the same columns as Wildcard:
+//
+//	sql := pgtools.NewQuery(User{}).Table("users").Where("id = $1").Build()
+//
+// It only builds the SQL string: it doesn't bind values, so pass the arguments of the placeholders
+// to the query yourself. Conditions and ordering are written in SQL, and aren't quoted or validated.
+//
+// Methods return a modified copy, so a QueryBuilder can be shared and extended safely.
+type QueryBuilder struct {
+	v       interface{}
+	table   string
+	where   []string
+	orderBy string
+	limit   int
+}
+
+// NewQuery returns a QueryBuilder selecting the columns of v.
+func NewQuery(v interface{}) QueryBuilder {
+	return QueryBuilder{v: v}
+}
+
+// Table to select from, quoted like Select.
+// If it isn't set, the table name of v is used if it implements Tabler,
+// and otherwise only the SELECT list and the other clauses are built.
+func (q QueryBuilder) Table(table string) QueryBuilder {
+	q.table = table
+	return q
+}
+
+// Where adds a condition to the WHERE clause.
+// Conditions added by multiple calls are combined with AND.
+func (q QueryBuilder) Where(condition string) QueryBuilder {
+	q.where = append(q.where[:len(q.where):len(q.where)], condition)
+	return q
+}
+
+// OrderBy sets the ORDER BY clause, such as "created_at DESC, id".
+func (q QueryBuilder) OrderBy(orderBy string) QueryBuilder {
+	q.orderBy = orderBy
+	return q
+}
+
+// Limit the number of rows returned. If n isn't positive, the LIMIT clause is omitted.
+func (q QueryBuilder) Limit(n int) QueryBuilder {
+	q.limit = n
+	return q
+}
+
+// Build returns the SQL statement.
+func (q QueryBuilder) Build() string {
+	var where string
+	switch len(q.where) {
+	case 0:
+	case 1:
+		where = q.where[0]
+	default:
+		where = "(" + strings.Join(q.where, ") AND (") + ")"
+	}
+	sql := SelectWhere(q.table, q.v, where)
+	if q.orderBy != "" {
+		sql += " ORDER BY " + q.orderBy
+	}
+	if q.limit > 0 {
+		sql += " LIMIT " + strconv.Itoa(q.limit)
+	}
+	return sql
+}
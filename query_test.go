@@ -0,0 +1,78 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleNewQuery() {
+	sql := pgtools.NewQuery(User{}).Table("user").Where("theme = $1").OrderBy("id DESC").Limit(10).Build()
+	fmt.Println(sql)
+	// Output:
+	// SELECT "username","full_name","email","id","theme" FROM "user" WHERE theme = $1 ORDER BY id DESC LIMIT 10
+}
+
+func TestQueryBuilder(t *testing.T) {
+	t.Parallel()
+	base := pgtools.NewQuery(&mock{}).Table("analytics.posts")
+	testCases := []struct {
+		desc string
+		got  string
+		want string
+	}{
+		{
+			desc: "select",
+			got:  pgtools.NewQuery(mock{}).Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase"`,
+		},
+		{
+			desc: "table",
+			got:  base.Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."posts"`,
+		},
+		{
+			desc: "where",
+			got:  base.Where("tagged = $1").Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."posts" WHERE tagged = $1`,
+		},
+		{
+			desc: "conditions",
+			got:  base.Where("tagged = $1 OR tagged = $2").Where("automatic = $3").Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."posts" WHERE (tagged = $1 OR tagged = $2) AND (automatic = $3)`,
+		},
+		{
+			desc: "order",
+			got:  base.OrderBy("one_two DESC").Limit(5).Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."posts" ORDER BY one_two DESC LIMIT 5`,
+		},
+		{
+			desc: "no limit",
+			got:  base.Limit(5).Limit(0).Build(),
+			want: `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."posts"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if tc.got != tc.want {
+				t.Errorf("expected query to be %v, got %v instead", tc.want, tc.got)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderCopy(t *testing.T) {
+	t.Parallel()
+	base := pgtools.NewQuery(mock{}).Where("tagged = $1")
+	a := base.Where("automatic = $2")
+	b := base.Where("one_two = $2")
+	if want, got := `SELECT "automatic","tagged","one_two","CamelCase" WHERE (tagged = $1) AND (automatic = $2)`, a.Build(); got != want {
+		t.Errorf("expected query to be %v, got %v instead", want, got)
+	}
+	if want, got := `SELECT "automatic","tagged","one_two","CamelCase" WHERE (tagged = $1) AND (one_two = $2)`, b.Build(); got != want {
+		t.Errorf("expected query to be %v, got %v instead", want, got)
+	}
+}
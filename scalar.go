@@ -0,0 +1,91 @@
+package pgtools
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// scalarValues registered with RegisterScalarValue, keyed by type.
+var scalarValues = struct {
+	mu sync.RWMutex // guards following
+	m  map[reflect.Type]func(interface{}) interface{}
+}{
+	m: map[reflect.Type]func(interface{}) interface{}{},
+}
+
+// RegisterScalar registers a struct type that maps to a single column, like time.Time,
+// instead of having its fields mapped to columns of their own.
+//
+// Struct types implementing sql.Scanner or driver.Valuer, such as sql.NullString and pgtype.Text,
+// are always mapped to a single column, so you only need it for other value types,
+// such as types implementing pgx's own encoding interfaces.
+// Other kinds of named types, such as type Status int, are never recursed, so registering them isn't required.
+// Pointers to t are handled the same way.
+//
+// Call it during initialization, before using the type with the other functions of this package.
+// Registering a type clears the Fields cache.
+func RegisterScalar(t reflect.Type) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	structref.RegisterScalar(t)
+
+	wildcardsCache.mu.Lock()
+	defer wildcardsCache.mu.Unlock()
+	wildcardsCache.clear()
+}
+
+// RegisterScalarValue registers a type that maps to a single column, like RegisterScalar,
+// and the function converting a value of the type to the value written to its column
+// by Args, NamedArgs, BulkInsert, and CopyRow.
+//
+// For example, to store an enum implementing fmt.Stringer in a text column instead of an integer column:
+//
+//	type Status int
+//
+//	func (s Status) String() string { ... }
+//
+//	pgtools.RegisterScalarValue(reflect.TypeOf(Status(0)), func(v interface{}) interface{} {
+//		return v.(Status).String()
+//	})
+//
+// The function receives a value of type t, and isn't called for a nil pointer to t, which is written as NULL.
+// Scanning the column back into the field is up to the type, such as by implementing sql.Scanner.
+//
+// Call it during initialization, before using the type with the other functions of this package.
+// Registering a type clears the Fields cache.
+func RegisterScalarValue(t reflect.Type, value func(v interface{}) interface{}) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	scalarValues.mu.Lock()
+	scalarValues.m[t] = value
+	scalarValues.mu.Unlock()
+	RegisterScalar(t)
+}
+
+// scalarValueFunc returns the function converting a field of type t to the value written to its column,
+// or nil if none was registered with RegisterScalarValue.
+func scalarValueFunc(t reflect.Type) func(interface{}) interface{} {
+	scalarValues.mu.RLock()
+	defer scalarValues.mu.RUnlock()
+	if fn, ok := scalarValues.m[t]; ok {
+		return fn
+	}
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	fn, ok := scalarValues.m[t.Elem()]
+	if !ok {
+		return nil
+	}
+	return func(v interface{}) interface{} {
+		rv := reflect.ValueOf(v)
+		if rv.IsNil() {
+			return nil
+		}
+		return fn(rv.Elem().Interface())
+	}
+}
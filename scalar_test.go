@@ -0,0 +1,81 @@
+package pgtools_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+// priority is an enum stored as an integer column.
+type priority int
+
+// state is an enum stored as a text column with RegisterScalarValue.
+type state int
+
+func (s state) String() string {
+	switch s {
+	case 1:
+		return "open"
+	case 2:
+		return "closed"
+	}
+	return "unknown"
+}
+
+type ticket struct {
+	ID       int64
+	Priority priority
+	State    state
+	Previous *state
+	Labels   []state
+}
+
+func TestRegisterScalarValue(t *testing.T) {
+	t.Parallel()
+	pgtools.RegisterScalarValue(reflect.TypeOf(state(0)), func(v interface{}) interface{} {
+		return v.(state).String()
+	})
+
+	if want, got := []string{"id", "priority", "state", "previous", "labels"}, pgtools.Fields(ticket{}); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected fields to be %v, got %v instead", want, got)
+	}
+	closed := state(2)
+	testCases := []struct {
+		desc string
+		v    ticket
+		want []interface{}
+	}{
+		{
+			desc: "nil pointer",
+			v:    ticket{ID: 1, Priority: 3, State: 1},
+			want: []interface{}{int64(1), priority(3), "open", nil, []state(nil)},
+		},
+		{
+			desc: "pointer",
+			v:    ticket{ID: 2, State: 1, Previous: &closed, Labels: []state{1}},
+			want: []interface{}{int64(2), priority(0), "open", "closed", []state{1}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.Args(tc.v); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected arguments to be %#v, got %#v instead", tc.want, got)
+			}
+		})
+	}
+
+	named := pgtools.NamedArgs(ticket{State: 2})
+	if want, got := "closed", named["state"]; want != got {
+		t.Errorf("expected named argument to be %v, got %v instead", want, got)
+	}
+	_, args, err := pgtools.BulkInsert("tickets", []ticket{{State: 1}, {State: 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []interface{}{"open", "closed"}, []interface{}{args[2], args[7]}; !reflect.DeepEqual(want, got) {
+		t.Errorf("expected bulk insert states to be %v, got %v instead", want, got)
+	}
+}
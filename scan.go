@@ -0,0 +1,108 @@
+package pgtools
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// ScanRow scans the current row of rows into dest, which must be a pointer to a struct.
+// Columns are mapped to struct fields the same way Fields reads them,
+// so you can scan the rows of a query using Wildcard without depending on scany:
+//
+//	rows, err := conn.Query(ctx, pgtools.Select("user", User{}))
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		var u User
+//		if err := pgtools.ScanRow(rows, &u); err != nil {
+//			return err
+//		}
+//		users = append(users, u)
+//	}
+//	return rows.Err()
+//
+// Nil pointers to nested structs are allocated as needed.
+// An error is returned if a column of the row has no matching field.
+func ScanRow(rows pgx.Rows, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		if dest == nil {
+			return errors.New("pgtools: cannot scan into nil")
+		}
+		return fmt.Errorf("pgtools: cannot scan into %T: not a pointer to a struct", dest)
+	}
+	rv = rv.Elem()
+	all := cachedTypeInfo(cacheKey{t: rv.Type(), tagKey: structref.DefaultTagKey}).all
+
+	fds := rows.FieldDescriptions()
+	targets := make([]interface{}, len(fds))
+	for i, fd := range fds {
+		pos, ok := all.index[string(fd.Name)]
+		if !ok {
+			return fmt.Errorf("pgtools: cannot scan column %q into %v: no matching field", fd.Name, rv.Type())
+		}
+		targets[i] = fieldByIndexAlloc(rv, all.fields[pos].Index).Addr().Interface()
+	}
+	return rows.Scan(targets...)
+}
+
+// fieldByIndexAlloc returns the nested field of v by index like reflect.Value.FieldByIndex,
+// allocating nil pointers to structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// AssignByColumn sets the struct field of dest mapped to the column colName to value,
+// such as to write your own scanner processing columns in any order.
+// Columns are mapped to struct fields the same way Fields reads them,
+// and dest must be a pointer to a struct.
+//
+// Nil pointers to nested structs are allocated as needed.
+// A nil value sets a pointer, interface, map, or slice field to nil.
+// An error is returned if there is no field for the column, or if value isn't assignable to the field,
+// as no conversion is done, so an int32 cannot be assigned to an int field.
+func AssignByColumn(dest interface{}, colName string, value interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		if dest == nil {
+			return errors.New("pgtools: cannot assign to nil")
+		}
+		return fmt.Errorf("pgtools: cannot assign to %T: not a pointer to a struct", dest)
+	}
+	rv = rv.Elem()
+	all := cachedTypeInfo(cacheKey{t: rv.Type(), tagKey: structref.DefaultTagKey}).all
+	pos, ok := all.index[colName]
+	if !ok {
+		return fmt.Errorf("pgtools: cannot assign column %q to %v: no matching field", colName, rv.Type())
+	}
+	field := fieldByIndexAlloc(rv, all.fields[pos].Index)
+	if value == nil {
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		return fmt.Errorf("pgtools: cannot assign nil to column %q of type %v", colName, field.Type())
+	}
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("pgtools: cannot assign %T to column %q of type %v", value, colName, field.Type())
+	}
+	field.Set(v)
+	return nil
+}
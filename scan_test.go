@@ -0,0 +1,203 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/partounian/pgtools"
+)
+
+// mockRows is a single row returned by a query.
+type mockRows struct {
+	columns []string
+	values  []interface{}
+}
+
+var _ pgx.Rows = (*mockRows)(nil)
+
+func (r *mockRows) Close()                        {}
+func (r *mockRows) Err() error                    { return nil }
+func (r *mockRows) CommandTag() pgconn.CommandTag { return nil }
+func (r *mockRows) Next() bool                    { return true }
+func (r *mockRows) Values() ([]interface{}, error) {
+	return r.values, nil
+}
+func (r *mockRows) RawValues() [][]byte { return nil }
+
+func (r *mockRows) FieldDescriptions() []pgproto3.FieldDescription {
+	fds := make([]pgproto3.FieldDescription, len(r.columns))
+	for i, c := range r.columns {
+		fds[i].Name = []byte(c)
+	}
+	return fds
+}
+
+func (r *mockRows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("got %d destinations, wanted %d", len(dest), len(r.values))
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+func TestScanRow(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc    string
+		rows    *mockRows
+		dest    interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			desc: "mock",
+			rows: &mockRows{
+				columns: []string{"tagged", "automatic", "CamelCase"},
+				values:  []interface{}{"tag", "auto", "camel"},
+			},
+			dest: &mock{},
+			want: &mock{Automatic: "auto", Tagged: "tag", CamelCase: "camel"},
+		},
+		{
+			desc: "nested",
+			rows: &mockRows{
+				columns: []string{"name", "address.street", "address.city"},
+				values:  []interface{}{"Alice", "Main St.", "Springfield"},
+			},
+			dest: &customer{},
+			want: &customer{Name: "Alice", Address: &address{Street: "Main St.", City: "Springfield"}},
+		},
+		{
+			desc: "unknown column",
+			rows: &mockRows{
+				columns: []string{"tagged", "ignored"},
+				values:  []interface{}{"tag", "ignored"},
+			},
+			dest:    &mock{},
+			wantErr: `pgtools: cannot scan column "ignored" into pgtools_test.mock: no matching field`,
+		},
+		{
+			desc:    "nil",
+			rows:    &mockRows{},
+			dest:    nil,
+			wantErr: "pgtools: cannot scan into nil",
+		},
+		{
+			desc:    "not a pointer",
+			rows:    &mockRows{},
+			dest:    mock{},
+			wantErr: "pgtools: cannot scan into pgtools_test.mock: not a pointer to a struct",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			err := pgtools.ScanRow(tc.rows, tc.dest)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("expected error to be %q, got %v instead", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.want, tc.dest) {
+				t.Errorf("expected value to be %+v, got %+v instead", tc.want, tc.dest)
+			}
+		})
+	}
+}
+
+func TestAssignByColumn(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc    string
+		dest    interface{}
+		column  string
+		value   interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			desc:   "tagged",
+			dest:   &mock{},
+			column: "tagged",
+			value:  "tag",
+			want:   &mock{Tagged: "tag"},
+		},
+		{
+			desc:   "nested",
+			dest:   &customer{Name: "Alice"},
+			column: "address.city",
+			value:  "Springfield",
+			want:   &customer{Name: "Alice", Address: &address{City: "Springfield"}},
+		},
+		{
+			desc:   "nil pointer",
+			dest:   &customer{Address: &address{}},
+			column: "address",
+			value:  nil,
+			want:   &customer{},
+		},
+		{
+			desc:    "nil",
+			dest:    &mock{Tagged: "tag"},
+			column:  "tagged",
+			value:   nil,
+			wantErr: `pgtools: cannot assign nil to column "tagged" of type string`,
+		},
+		{
+			desc:    "not assignable",
+			dest:    &numericMock{},
+			column:  "number",
+			value:   int32(1),
+			wantErr: `pgtools: cannot assign int32 to column "number" of type int`,
+		},
+		{
+			desc:    "unknown column",
+			dest:    &mock{},
+			column:  "ignored",
+			value:   "ignored",
+			wantErr: `pgtools: cannot assign column "ignored" to pgtools_test.mock: no matching field`,
+		},
+		{
+			desc:    "nil dest",
+			dest:    nil,
+			column:  "tagged",
+			wantErr: "pgtools: cannot assign to nil",
+		},
+		{
+			desc:    "not a pointer",
+			dest:    mock{},
+			column:  "tagged",
+			wantErr: "pgtools: cannot assign to pgtools_test.mock: not a pointer to a struct",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			err := pgtools.AssignByColumn(tc.dest, tc.column, tc.value)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("expected error to be %q, got %v instead", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.want, tc.dest) {
+				t.Errorf("expected value to be %+v, got %+v instead", tc.want, tc.dest)
+			}
+		})
+	}
+}
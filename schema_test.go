@@ -0,0 +1,74 @@
+package pgtools_test
+
+import (
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+	type base struct {
+		ID   int64
+		Name string
+	}
+	type same struct {
+		ID   int64
+		Name string `db:"name,readonly"`
+	}
+	type added struct {
+		ID    int64
+		Name  string
+		Email string
+	}
+	type removed struct {
+		ID int64
+	}
+	type renamed struct {
+		ID       int64
+		FullName string
+	}
+	type retyped struct {
+		ID   int32
+		Name string
+	}
+	type reordered struct {
+		Name string
+		ID   int64
+	}
+	want := pgtools.Schema(base{})
+	if len(want) != 64 {
+		t.Fatalf("expected schema to be a SHA-256 hex digest, got %q instead", want)
+	}
+	if got := pgtools.Schema(&base{}); got != want {
+		t.Errorf("expected schema of pointer to be %v, got %v instead", want, got)
+	}
+	if got := pgtools.Schema(same{}); got != want {
+		t.Errorf("expected schema of the same columns to be %v, got %v instead", want, got)
+	}
+	testCases := []struct {
+		desc string
+		v    interface{}
+	}{
+		{desc: "added", v: added{}},
+		{desc: "removed", v: removed{}},
+		{desc: "renamed", v: renamed{}},
+		{desc: "retyped", v: retyped{}},
+		{desc: "reordered", v: reordered{}},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.Schema(tc.v); got == want {
+				t.Errorf("expected schema to change, got %v instead", got)
+			}
+		})
+	}
+	if got := pgtools.Schema(emptyEmbed{}); got != "" {
+		t.Errorf("expected empty schema, got %v instead", got)
+	}
+	if got := pgtools.Schema(nil); got != "" {
+		t.Errorf("expected empty schema, got %v instead", got)
+	}
+}
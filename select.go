@@ -0,0 +1,32 @@
+package pgtools
+
+// Select returns a SELECT statement for the given table, listing the same columns as Wildcard, such as:
+//
+//	SELECT "username","full_name","email" FROM "user"
+//
+// If table is empty, the table name of v is used if it implements Tabler.
+// Otherwise, only the SELECT list is returned, so you can write the FROM clause yourself.
+// The table can be qualified with a schema, as in "analytics.events", which is quoted as "analytics"."events".
+func Select(table string, v interface{}) string {
+	if table == "" {
+		table = TableOf(v)
+	}
+	sql := "SELECT " + Wildcard(v)
+	if table != "" {
+		sql += ` FROM ` + quoteTable(table)
+	}
+	return sql
+}
+
+// SelectWhere returns a SELECT statement like Select, with the given WHERE condition, such as:
+//
+//	SELECT "username","full_name","email" FROM "user" WHERE id = $1
+//
+// If where is empty, the WHERE clause is omitted.
+func SelectWhere(table string, v interface{}, where string) string {
+	sql := Select(table, v)
+	if where != "" {
+		sql += " WHERE " + where
+	}
+	return sql
+}
@@ -0,0 +1,72 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleSelectWhere() {
+	sql := pgtools.SelectWhere("user", User{}, "id = $1")
+	fmt.Println(sql)
+	// Output:
+	// SELECT "username","full_name","email","id","theme" FROM "user" WHERE id = $1
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc  string
+		table string
+		v     interface{}
+		where string
+		want  string
+	}{
+		{
+			desc:  "table",
+			table: "posts",
+			v:     &mock{},
+			want:  `SELECT "automatic","tagged","one_two","CamelCase" FROM "posts"`,
+		},
+		{
+			desc:  "where",
+			table: "posts",
+			v:     mock{},
+			where: "tagged = $1",
+			want:  `SELECT "automatic","tagged","one_two","CamelCase" FROM "posts" WHERE tagged = $1`,
+		},
+		{
+			desc:  "schema",
+			table: "analytics.events",
+			v:     mock{},
+			where: "tagged = $1",
+			want:  `SELECT "automatic","tagged","one_two","CamelCase" FROM "analytics"."events" WHERE tagged = $1`,
+		},
+		{
+			desc: "no table",
+			v:    mock{},
+			want: `SELECT "automatic","tagged","one_two","CamelCase"`,
+		},
+		{
+			desc:  "nested",
+			table: "nested",
+			v:     customer{},
+			want:  `SELECT "name","address.street" as "address.street","address.city" as "address.city","address" FROM "nested"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.SelectWhere(tc.table, tc.v, tc.where); got != tc.want {
+				t.Errorf("expected statement to be %v, got %v instead", tc.want, got)
+			}
+			if tc.where == "" {
+				if got := pgtools.Select(tc.table, tc.v); got != tc.want {
+					t.Errorf("expected statement to be %v, got %v instead", tc.want, got)
+				}
+			}
+		})
+	}
+}
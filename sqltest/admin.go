@@ -0,0 +1,64 @@
+package sqltest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// adminLocks serialize the use of the connections set by Options.AdminConn,
+// as a connection cannot be used concurrently by the tests sharing it.
+var adminLocks = struct {
+	mu sync.Mutex // guards following
+	m  map[*pgx.Conn]*sync.Mutex
+}{
+	m: map[*pgx.Conn]*sync.Mutex{},
+}
+
+// openAdmin returns the connection used to create and drop the temporary database or schema,
+// connecting to the database unless Options.AdminConn is set.
+func (m *Migration) openAdmin(ctx context.Context, connString string) (*pgx.Conn, error) {
+	if m.Options.AdminConn != nil {
+		return m.Options.AdminConn, nil
+	}
+	return m.connect(ctx, connString)
+}
+
+// closeAdmin connection, unless it was set by Options.AdminConn, as it's owned by the caller.
+func (m *Migration) closeAdmin(ctx context.Context) {
+	if m.conn == nil || m.conn == m.Options.AdminConn {
+		return
+	}
+	m.conn.Close(ctx)
+}
+
+// lockAdmin connection, if it's shared with other tests by Options.AdminConn.
+func (m *Migration) lockAdmin() (unlock func()) {
+	if m.Options.AdminConn == nil {
+		return func() {}
+	}
+	adminLocks.mu.Lock()
+	mu, ok := adminLocks.m[m.Options.AdminConn]
+	if !ok {
+		mu = &sync.Mutex{}
+		adminLocks.m[m.Options.AdminConn] = mu
+	}
+	adminLocks.mu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// adminExec executes sql on the admin connection.
+func (m *Migration) adminExec(ctx context.Context, sql string, args ...interface{}) error {
+	defer m.lockAdmin()()
+	_, err := m.conn.Exec(ctx, sql, args...)
+	return err
+}
+
+// adminQueryRow executes a query returning a single row on the admin connection,
+// and scans it into dest.
+func (m *Migration) adminQueryRow(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	defer m.lockAdmin()()
+	return m.conn.QueryRow(ctx, sql, args...).Scan(dest)
+}
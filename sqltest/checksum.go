@@ -0,0 +1,74 @@
+package sqltest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/tern/migrate"
+)
+
+// checksumTable returns the name of the table where the checksums of the migrations applied
+// are saved, named after the version table.
+func checksumTable(versionTable string) string {
+	return versionTable + "_checksums"
+}
+
+// checksum of the SQL of a migration.
+func checksum(m *migrate.Migration) string {
+	h := sha256.Sum256([]byte(m.UpSQL))
+	return hex.EncodeToString(h[:])
+}
+
+// verifyChecksums of the migrations applied to the database, so that editing a migration file
+// after it was applied is caught instead of silently diverging environments.
+// Every saved checksum is verified, not only the ones of the current version, as Teardown migrates
+// a database used with UseExisting back down, and the checksums are kept for the next run.
+func verifyChecksums(ctx context.Context, conn *pgx.Conn, migrator *migrate.Migrator, versionTable string) error {
+	if err := createChecksumTable(ctx, conn, versionTable); err != nil {
+		return err
+	}
+	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT version, checksum FROM %s ORDER BY version", checksumTable(versionTable)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version int32
+			applied string
+		)
+		if err := rows.Scan(&version, &applied); err != nil {
+			return err
+		}
+		if version < 1 {
+			return fmt.Errorf("invalid version %d in %s", version, checksumTable(versionTable))
+		}
+		if int(version) > len(migrator.Migrations) {
+			return fmt.Errorf("migration %d was applied, but it's missing", version)
+		}
+		mig := migrator.Migrations[version-1]
+		if got := checksum(mig); got != applied {
+			return fmt.Errorf("migration %d (%s) was modified after it was applied: checksum is %s, but %s was applied", version, mig.Name, got, applied)
+		}
+	}
+	return rows.Err()
+}
+
+// saveChecksums of the migrations applied to the database.
+func saveChecksums(ctx context.Context, conn *pgx.Conn, migrator *migrate.Migrator, versionTable string) error {
+	b := &pgx.Batch{}
+	for _, mig := range migrator.Migrations {
+		b.Queue(fmt.Sprintf(`INSERT INTO %s (version, checksum) VALUES ($1, $2)
+			ON CONFLICT (version) DO UPDATE SET checksum = EXCLUDED.checksum`, checksumTable(versionTable)), mig.Sequence, checksum(mig))
+	}
+	return conn.SendBatch(ctx, b).Close()
+}
+
+// createChecksumTable if it doesn't exist yet.
+func createChecksumTable(ctx context.Context, conn *pgx.Conn, versionTable string) error {
+	_, err := conn.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version int4 PRIMARY KEY, checksum text NOT NULL)", checksumTable(versionTable)))
+	return err
+}
@@ -0,0 +1,81 @@
+package sqltest
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ConnString returns connString with the connection settings from the options applied.
+//
+// Settings are resolved in the following order of precedence:
+//
+//  1. Host, Port, User, Password, SSLMode, and SSLRootCert options, if set.
+//  2. Settings from connString, which can be a URL or keyword/value connection string.
+//  3. PostgreSQL environment variables, such as PGHOST and PGUSER.
+//  4. Defaults, such as localhost and port 5432.
+//
+// Setup calls it with the connection string it receives, so you don't need to call it yourself.
+func (o Options) ConnString(connString string) (string, error) {
+	settings := o.connSettings()
+	if len(settings) == 0 {
+		return connString, nil
+	}
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse connection string: %w", err)
+		}
+		// Query parameters take precedence over the host and user information of the URL.
+		q := u.Query()
+		for _, s := range settings {
+			q.Set(s[0], s[1])
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	// Later settings take precedence over earlier ones in a keyword/value connection string.
+	var b strings.Builder
+	b.WriteString(connString)
+	for _, s := range settings {
+		if b.Len() != 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(s[0])
+		b.WriteString("=")
+		b.WriteString(quoteConnValue(s[1]))
+	}
+	return b.String(), nil
+}
+
+// connSettings returns the keyword and value of the connection settings set in the options.
+func (o Options) connSettings() [][2]string {
+	var settings [][2]string
+	add := func(keyword, value string) {
+		if value != "" {
+			settings = append(settings, [2]string{keyword, value})
+		}
+	}
+	add("host", o.Host)
+	if o.Port != 0 {
+		add("port", strconv.FormatUint(uint64(o.Port), 10))
+	}
+	add("user", o.User)
+	add("password", o.Password)
+	add("sslmode", o.SSLMode)
+	add("sslrootcert", o.SSLRootCert)
+	return settings
+}
+
+// quoteConnValue quotes a value of a keyword/value connection string if required.
+// Reference: https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING-KEYWORD-VALUE
+func quoteConnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
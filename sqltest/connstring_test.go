@@ -0,0 +1,83 @@
+package sqltest_test
+
+import (
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestOptionsConnString(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc       string
+		opts       sqltest.Options
+		connString string
+		want       string
+	}{
+		{
+			desc:       "unset",
+			connString: "host=example.com",
+			want:       "host=example.com",
+		},
+		{
+			desc: "empty",
+			opts: sqltest.Options{Host: "db", Port: 5433, User: "alice"},
+			want: "host=db port=5433 user=alice",
+		},
+		{
+			desc:       "keyword",
+			opts:       sqltest.Options{Host: "db", Password: `it's a \secret`, SSLMode: "disable"},
+			connString: "host=example.com user=bob",
+			want:       `host=example.com user=bob host=db password='it\'s a \\secret' sslmode=disable`,
+		},
+		{
+			desc:       "tls",
+			opts:       sqltest.Options{SSLMode: "verify-full", SSLRootCert: "/etc/ssl/certs/root ca.pem"},
+			connString: "host=db.example.com",
+			want:       "host=db.example.com sslmode=verify-full sslrootcert='/etc/ssl/certs/root ca.pem'",
+		},
+		{
+			desc:       "url",
+			opts:       sqltest.Options{Host: "db", User: "alice"},
+			connString: "postgres://bob@example.com:5432/test",
+			want:       "postgres://bob@example.com:5432/test?host=db&user=alice",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.opts.ConnString(tc.connString)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected connection string to be %q, got %q instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOptionsConnStringPrecedence(t *testing.T) {
+	t.Setenv("PGHOST", "env.example.com")
+	t.Setenv("PGUSER", "env")
+	opts := sqltest.Options{User: "alice", Password: "it's a secret"}
+	connString, err := opts.ConnString("user=bob password=hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config, err := pgconn.ParseConfig(connString)
+	if err != nil {
+		t.Fatalf("cannot parse connection string: %v", err)
+	}
+	if want := "env.example.com"; config.Host != want {
+		t.Errorf("expected host to be %q, got %q instead", want, config.Host)
+	}
+	if want := "alice"; config.User != want {
+		t.Errorf("expected user to be %q, got %q instead", want, config.User)
+	}
+	if want := "it's a secret"; config.Password != want {
+		t.Errorf("expected password to be %q, got %q instead", want, config.Password)
+	}
+}
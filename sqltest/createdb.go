@@ -0,0 +1,31 @@
+package sqltest
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// databaseSettings returns the clauses of CREATE DATABASE for the Template, Encoding, and Locale options,
+// or an empty string if none of them is set.
+func (o Options) databaseSettings() string {
+	var b strings.Builder
+	if o.Template != "" {
+		b.WriteString(" TEMPLATE ")
+		b.WriteString(pgx.Identifier{o.Template}.Sanitize())
+	}
+	if o.Encoding != "" {
+		b.WriteString(" ENCODING ")
+		b.WriteString(quoteLiteral(o.Encoding))
+	}
+	if o.Locale != "" {
+		b.WriteString(" LOCALE ")
+		b.WriteString(quoteLiteral(o.Locale))
+	}
+	return b.String()
+}
+
+// quoteLiteral quotes a string literal, escaping single quotes.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,109 @@
+package sqltest
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateData returns Options.TemplateData as the map tern executes migration templates with,
+// or nil if it isn't set.
+//
+// A struct is converted to a map of its exported fields, so they're referenced the same way,
+// as in {{.Tablespace}}, but its methods aren't available.
+func templateData(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if data, ok := v.(map[string]interface{}); ok {
+		return data, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		data := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			data[iter.Key().String()] = iter.Value().Interface()
+		}
+		return data, nil
+	case rv.Kind() == reflect.Struct:
+		data := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			if f := rv.Type().Field(i); f.PkgPath == "" {
+				data[f.Name] = rv.Field(i).Interface()
+			}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("invalid TemplateData: must be a map with string keys or a struct, got %T", v)
+}
+
+// renderMigration executes the SQL of a golang-migrate style migration as a template with data,
+// as tern does for migrations using its format.
+func renderMigration(name, sql string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeValue writes v to w in a deterministic way, such as to hash the template data.
+// Pointers and interfaces are written as the values they point to, so that addresses don't change the output,
+// and maps are written with their keys sorted.
+func writeValue(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		io.WriteString(w, "nil")
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			io.WriteString(w, "nil")
+			return
+		}
+		writeValue(w, v.Elem())
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		fmt.Fprintf(w, "%s{", v.Type())
+		for _, k := range keys {
+			writeValue(w, k)
+			io.WriteString(w, ":")
+			writeValue(w, v.MapIndex(k))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "}")
+	case reflect.Struct:
+		fmt.Fprintf(w, "%s{", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(w, "%s:", v.Type().Field(i).Name)
+			writeValue(w, v.Field(i))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "}")
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(w, "%s[", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			writeValue(w, v.Index(i))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// Only their type is written, as their value is an address.
+		fmt.Fprintf(w, "%s", v.Type())
+	default:
+		fmt.Fprintf(w, "%#v", v)
+	}
+}
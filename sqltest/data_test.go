@@ -0,0 +1,39 @@
+package sqltest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestTemplateDataInvalid(t *testing.T) {
+	t.Parallel()
+	opts := sqltest.Options{
+		Path:         "testdata/template-data/tern",
+		TemplateData: []string{"widgets"},
+		Logger:       t,
+	}
+	// Setup fails before connecting to the database.
+	_, err := sqltest.NewShared(opts).Setup(context.Background(), "")
+	if want := "invalid TemplateData: must be a map with string keys or a struct, got []string"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error to contain %q, got %v instead", want, err)
+	}
+}
+
+func TestTemplateDataValidate(t *testing.T) {
+	t.Parallel()
+	opts := sqltest.Options{
+		Path:         "testdata/template-data/updown",
+		TemplateData: map[string]string{"Table": "widgets"},
+	}
+	if err := sqltest.Validate(opts); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	opts.TemplateData = 42
+	if want := "invalid TemplateData"; !strings.Contains(fmt.Sprint(sqltest.Validate(opts)), want) {
+		t.Errorf("expected error to contain %q, got %v instead", want, sqltest.Validate(opts))
+	}
+}
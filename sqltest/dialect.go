@@ -0,0 +1,64 @@
+package sqltest
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Dialect of the database server sqltest runs against.
+type Dialect int
+
+const (
+	// Postgres is the default dialect, for PostgreSQL.
+	Postgres Dialect = iota
+
+	// Cockroach is for CockroachDB, which speaks the PostgreSQL wire protocol,
+	// but doesn't support all of its DDL.
+	//
+	// CREATE DATABASE ... TEMPLATE isn't supported, so Setup fails if UseTemplate, Template, Encoding, or Locale is set,
+	// and TRUNCATE doesn't restart sequences, so Truncate and PoolSize keep their current values.
+	// Advisory locks aren't supported either, which tern takes while migrating, so a custom Runner is required,
+	// and the migrations must be written for CockroachDB.
+	// ResetSequences fails, as it relies on setval with a regclass, and CleanupOrphans only supports PostgreSQL.
+	Cockroach
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "PostgreSQL"
+	case Cockroach:
+		return "CockroachDB"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// checkDialect returns an error if the options use a feature the dialect doesn't support.
+func (o Options) checkDialect() error {
+	switch o.Dialect {
+	case Postgres:
+		return nil
+	case Cockroach:
+		if o.UseTemplate && !o.UseExisting {
+			return errors.New("cannot use UseTemplate with CockroachDB: CREATE DATABASE ... TEMPLATE isn't supported")
+		}
+		if (o.Template != "" || o.Encoding != "" || o.Locale != "") && !o.UseExisting && !o.UseSchema {
+			return errors.New("cannot use Template, Encoding, or Locale with CockroachDB: " +
+				"CREATE DATABASE ... TEMPLATE, ENCODING, and LOCALE aren't supported")
+		}
+		if o.Runner == nil {
+			return errors.New("cannot use CockroachDB without a Runner: tern takes advisory locks, which aren't supported")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown dialect: %v", o.Dialect)
+}
+
+// truncateOptions returns the options of TRUNCATE supported by the dialect.
+func (d Dialect) truncateOptions() string {
+	if d == Cockroach {
+		return "CASCADE"
+	}
+	return "RESTART IDENTITY CASCADE"
+}
@@ -0,0 +1,52 @@
+package sqltest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestDialectUnsupported(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		opts sqltest.Options
+		want string
+	}{
+		{
+			desc: "template",
+			opts: sqltest.Options{Dialect: sqltest.Cockroach, UseTemplate: true},
+			want: "cannot use UseTemplate with CockroachDB",
+		},
+		{
+			desc: "database settings",
+			opts: sqltest.Options{Dialect: sqltest.Cockroach, Template: "template0", Encoding: "UTF8", Locale: "C"},
+			want: "cannot use Template, Encoding, or Locale with CockroachDB",
+		},
+		{
+			desc: "runner",
+			opts: sqltest.Options{Dialect: sqltest.Cockroach},
+			want: "cannot use CockroachDB without a Runner",
+		},
+		{
+			desc: "unknown",
+			opts: sqltest.Options{Dialect: 42},
+			want: "unknown dialect: Dialect(42)",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			tc.opts.Path = "example/testdata/migrations"
+			tc.opts.Logger = t
+			// Setup fails before connecting to the database.
+			_, err := sqltest.NewShared(tc.opts).Setup(context.Background(), "")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error to contain %q, got %v instead", tc.want, err)
+			}
+		})
+	}
+}
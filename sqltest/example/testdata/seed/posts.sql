@@ -0,0 +1,3 @@
+INSERT INTO posts (id, name, message) VALUES
+	('1', 'hello', 'Hello, world!'),
+	('2', 'bye', 'Goodbye, world!');
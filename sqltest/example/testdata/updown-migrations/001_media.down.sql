@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS media;
+DROP TYPE IF EXISTS media_type;
@@ -0,0 +1,10 @@
+CREATE TYPE media_type AS ENUM ('photo', 'illustration', 'sketch');
+
+CREATE TABLE media (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	source media_type NOT NULL,
+	url text NOT NULL,
+	created_at timestamp with time zone NOT NULL DEFAULT now(),
+	modified_at timestamp with time zone NOT NULL DEFAULT now()
+);
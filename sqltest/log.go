@@ -0,0 +1,96 @@
+package sqltest
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/tern/migrate"
+)
+
+// Logger used by sqltest to log what it does, such as the migration files it applies.
+// testing.TB implements it.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// logf using Options.Logger, or the test if not set.
+func (m *Migration) logf(format string, args ...interface{}) {
+	if m.Options.Logger != nil {
+		m.Options.Logger.Logf(format, args...)
+		return
+	}
+	m.t.Helper()
+	m.t.Logf(format, args...)
+}
+
+// logFailedStatement of a migration, as tern executes each migration file at once,
+// and the error it returns doesn't say which statement failed.
+//
+// If PostgreSQL doesn't report the position of the error, and conn isn't nil,
+// the statements of the migration are executed one at a time in a transaction that is rolled back
+// to find the one that failed, as tern rolls back the migration when it fails.
+// Only pass conn if the migrations applied before the failed one are still applied.
+func (m *Migration) logFailedStatement(ctx context.Context, conn *pgx.Conn, err error) {
+	var pgErr migrate.MigrationPgError
+	if !errors.As(err, &pgErr) || pgErr.PgError == nil {
+		return
+	}
+	statements := splitStatements(pgErr.Sql)
+	index, offset := -1, -1
+	if pgErr.Position > 0 {
+		offset = byteOffset(pgErr.Sql, int(pgErr.Position))
+		for i, s := range statements {
+			if s.Offset <= offset {
+				index = i
+			}
+		}
+	} else if conn != nil {
+		index, offset = findFailedStatement(ctx, conn, statements)
+	}
+	if index < 0 {
+		return
+	}
+	s := statements[index]
+	line := s.Line
+	if offset >= 0 {
+		line = 1 + strings.Count(pgErr.Sql[:offset], "\n")
+	}
+	m.logf("migration failed at line %d, statement %d of %d:\n%s", line, index+1, len(statements), s.SQL)
+}
+
+// findFailedStatement executes the statements one at a time in a transaction,
+// and returns the index of the first one that fails, and the offset of the error in the file.
+// The transaction is rolled back.
+func findFailedStatement(ctx context.Context, conn *pgx.Conn, statements []statement) (index, offset int) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return -1, -1
+	}
+	defer tx.Rollback(ctx)
+	for i, s := range statements {
+		if _, err := tx.Exec(ctx, s.SQL); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Position > 0 {
+				return i, s.Offset + byteOffset(s.SQL, int(pgErr.Position))
+			}
+			return i, -1
+		}
+	}
+	return -1, -1
+}
+
+// byteOffset returns the offset in bytes of a position reported by PostgreSQL,
+// which is the 1-based index of a character.
+func byteOffset(sql string, position int) int {
+	var n int
+	for i := range sql {
+		n++
+		if n == position {
+			return i
+		}
+	}
+	return len(sql)
+}
@@ -0,0 +1,367 @@
+package sqltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing/fstest"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/tern/migrate"
+)
+
+// upDownPattern matches golang-migrate style migration files, such as 001_posts.up.sql and 001_posts.down.sql.
+var upDownPattern = regexp.MustCompile(`\A(\d+)_(.+)\.(up|down)\.sql\z`)
+
+// osFS reads files from the operating system's filesystem, with paths relative
+// to the current directory, as Options.Path did before Options.FS was introduced.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)                 { return os.Open(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error)        { return os.ReadDir(name) }
+func (osFS) ReadFile(name string) ([]byte, error)              { return os.ReadFile(name) }
+func (osFS) Glob(pattern string) (matches []string, err error) { return filepath.Glob(pattern) }
+
+// migratorFS adapts a fs.FS to the interface tern uses to read migrations.
+type migratorFS struct {
+	fsys fs.FS
+}
+
+func (f migratorFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(f.fsys, dirname)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (f migratorFS) ReadFile(filename string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, filename)
+}
+
+func (f migratorFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(f.fsys, pattern)
+}
+
+// migrationsFS returns the filesystem and directory containing the migrations.
+func (m *Migration) migrationsFS() (fsys fs.FS, dir string) {
+	if m.merged != nil {
+		return m.merged, "."
+	}
+	if m.Options.FS == nil {
+		return osFS{}, m.Options.Path
+	}
+	if m.Options.Path == "" {
+		return m.Options.FS, "."
+	}
+	return m.Options.FS, m.Options.Path
+}
+
+// versionPattern matches the version of a migration file, such as 001 in 001_posts.sql.
+var versionPattern = regexp.MustCompile(`\A(\d+)_`)
+
+// mergeMigrations copies the migration files in Options.Path and Options.Paths
+// to a single directory in memory, so they're applied in order of their file names.
+// Subdirectories, such as those containing tern's shared templates, aren't copied.
+func (m *Migration) mergeMigrations() (fs.FS, error) {
+	fsys := m.Options.FS
+	if fsys == nil {
+		fsys = osFS{}
+	}
+	dirs := m.Options.Paths
+	if m.Options.Path != "" {
+		dirs = append([]string{m.Options.Path}, dirs...)
+	}
+	merged := fstest.MapFS{}
+	versions := map[int64]string{} // directory of each version
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			name := e.Name()
+			if matches := versionPattern.FindStringSubmatch(name); matches != nil {
+				version, err := strconv.ParseInt(matches[1], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid migration version %q: %w", name, err)
+				}
+				if other, ok := versions[version]; ok && other != dir {
+					return nil, fmt.Errorf("duplicate migration version %d in %s and %s", version, other, dir)
+				}
+				versions[version] = dir
+			}
+			if _, ok := merged[name]; ok {
+				return nil, fmt.Errorf("duplicate migration file %s in %s", name, dir)
+			}
+			b, err := fs.ReadFile(fsys, path.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			merged[name] = &fstest.MapFile{Data: b}
+		}
+	}
+	return merged, nil
+}
+
+// migrationsSource returns the directory containing the migrations as a fs.FS, to pass to a MigrationRunner.
+func (m *Migration) migrationsSource() (fs.FS, error) {
+	if m.merged != nil {
+		return m.merged, nil
+	}
+	if m.Options.FS == nil {
+		return os.DirFS(m.Options.Path), nil
+	}
+	return fs.Sub(m.migrationsFS())
+}
+
+// runMigrations using the custom Options.Runner.
+func (m *Migration) runMigrations(ctx context.Context, conn *pgx.Conn) error {
+	source, err := m.migrationsSource()
+	if err != nil {
+		return fmt.Errorf("cannot load migrations: %w", err)
+	}
+	if err := m.Options.Runner.Run(ctx, conn, source); err != nil {
+		return fmt.Errorf("cannot apply migrations: %w", err)
+	}
+	return nil
+}
+
+// newMigrator reading migrations from Options.FS or Options.Path.
+func (m *Migration) newMigrator(ctx context.Context, conn *pgx.Conn) (*migrate.Migrator, error) {
+	fsys, _ := m.migrationsFS()
+	migrator, err := migrate.NewMigratorEx(ctx, conn, m.versionTable(), &migrate.MigratorOptions{
+		// Options.SingleTransaction wraps all migrations in a transaction instead.
+		DisableTx:  m.Options.SingleTransaction,
+		MigratorFS: migratorFS{fsys: fsys},
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := templateData(m.Options.TemplateData)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		migrator.Data = data
+	}
+	migrator.OnStart = func(sequence int32, name, direction, sql string) {
+		m.logf("executing %s %s", name, direction)
+	}
+	return migrator, nil
+}
+
+// applyMigrations using tern, undoing the applied migrations first if undo is set.
+// If Options.SingleTransaction is set, all migrations are applied in a single transaction.
+func (m *Migration) applyMigrations(ctx context.Context, conn *pgx.Conn, migrator *migrate.Migrator, undo bool) error {
+	apply := func() error {
+		if undo {
+			if err := migrator.MigrateTo(ctx, 0); err != nil {
+				return fmt.Errorf("cannot undo database migrations: %w", err)
+			}
+		}
+		if err := migrator.Migrate(ctx); err != nil {
+			return fmt.Errorf("cannot apply migrations: %w", err)
+		}
+		if m.Options.VerifyIdempotent {
+			return reapplyMigrations(ctx, conn, migrator, !m.Options.SingleTransaction)
+		}
+		return nil
+	}
+	var err error
+	if m.Options.SingleTransaction {
+		if err := checkSingleTransaction(migrator); err != nil {
+			return err
+		}
+		err = inTransaction(ctx, conn, apply)
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		// The failed migration is rolled back, and the migrations before it are still applied,
+		// so its statements can be executed again to find the one that failed.
+		// With SingleTransaction, every migration is rolled back, so the statements would run against
+		// a database missing the objects they depend on, and only the position reported by PostgreSQL is used.
+		replayConn := conn
+		if m.Options.SingleTransaction {
+			replayConn = nil
+		}
+		m.logFailedStatement(ctx, replayConn, err)
+	}
+	return err
+}
+
+// reapplyMigrations executes the up migrations again, to verify they're idempotent.
+// If inTx is set, each migration is executed in a transaction of its own, as tern does.
+func reapplyMigrations(ctx context.Context, conn *pgx.Conn, migrator *migrate.Migrator, inTx bool) error {
+	for _, mig := range migrator.Migrations {
+		exec := func() error {
+			_, err := conn.Exec(ctx, mig.UpSQL)
+			return err
+		}
+		var err error
+		if inTx {
+			err = inTransaction(ctx, conn, exec)
+		} else {
+			err = exec()
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				// Let logFailedStatement find the statement that failed.
+				err = migrate.MigrationPgError{Sql: mig.UpSQL, PgError: pgErr}
+			}
+			return fmt.Errorf("migration %s isn't idempotent: %w", mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// inTransaction calls fn in a transaction, which is committed if fn succeeds, and rolled back otherwise.
+func inTransaction(ctx context.Context, conn *pgx.Conn, fn func() error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return nil
+}
+
+// checkSingleTransaction returns an error if a migration cannot be applied in a single transaction,
+// as it uses CONCURRENTLY, which isn't allowed in a transaction block.
+func checkSingleTransaction(migrator *migrate.Migrator) error {
+	for _, mig := range migrator.Migrations {
+		for i, s := range splitStatements(mig.UpSQL) {
+			for _, word := range strings.Fields(strings.ToUpper(stripComments(s.SQL))) {
+				if strings.TrimSuffix(word, ";") == "CONCURRENTLY" {
+					return fmt.Errorf("cannot apply migrations in a single transaction: %s uses CONCURRENTLY at line %d, statement %d", mig.Name, s.Line, i+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadMigrations from the migration path.
+//
+// Migration files using tern's format are loaded by tern.
+// If the directory contains golang-migrate style *.up.sql files, they're paired with
+// their *.down.sql counterparts instead.
+func (m *Migration) loadMigrations(migrator *migrate.Migrator) error {
+	fsys, dir := m.migrationsFS()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	var upDown bool
+	for _, e := range entries {
+		if !e.IsDir() && upDownPattern.MatchString(e.Name()) {
+			upDown = true
+			break
+		}
+	}
+	if !upDown {
+		m.downMigrations = true
+		return migrator.LoadMigrations(dir)
+	}
+	m.downMigrations = m.Options.RunDownMigrations
+	return m.loadUpDownMigrations(migrator, fsys, dir, entries)
+}
+
+// upDownMigration is a golang-migrate style migration.
+type upDownMigration struct {
+	version int64
+	name    string
+	up      *string
+	down    *string
+}
+
+// loadUpDownMigrations loads golang-migrate style migrations ordered by version.
+func (m *Migration) loadUpDownMigrations(migrator *migrate.Migrator, fsys fs.FS, dir string, entries []fs.DirEntry) error {
+	data, err := templateData(m.Options.TemplateData)
+	if err != nil {
+		return err
+	}
+	versions := map[int64]*upDownMigration{}
+	for _, e := range entries {
+		matches := upDownPattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration version %q: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		ud, ok := versions[version]
+		if !ok {
+			ud = &upDownMigration{version: version}
+			versions[version] = ud
+		}
+		sql := string(b)
+		if m.Options.TemplateData != nil {
+			if sql, err = renderMigration(e.Name(), sql, data); err != nil {
+				return fmt.Errorf("cannot execute template of migration %s: %w", e.Name(), err)
+			}
+		}
+		switch direction := matches[3]; {
+		case direction == "up" && ud.up != nil, direction == "down" && ud.down != nil:
+			return fmt.Errorf("duplicate %s migration for version %d", direction, version)
+		case direction == "up":
+			ud.name, ud.up = e.Name(), &sql
+		default:
+			ud.down = &sql
+		}
+	}
+
+	migrations := make([]*upDownMigration, 0, len(versions))
+	for _, ud := range versions {
+		migrations = append(migrations, ud)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for _, ud := range migrations {
+		if ud.up == nil {
+			return fmt.Errorf("missing up migration for version %d", ud.version)
+		}
+		var down string
+		switch {
+		case ud.down != nil:
+			down = *ud.down
+		case m.Options.RunDownMigrations:
+			return fmt.Errorf("missing down migration for version %d (%s)", ud.version, ud.name)
+		}
+		migrator.AppendMigration(ud.name, *ud.up, down)
+	}
+	return nil
+}
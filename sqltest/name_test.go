@@ -0,0 +1,34 @@
+package sqltest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestSQLTestNameLong(t *testing.T) {
+	t.Parallel()
+	const long = "a_very_long_subtest_name_shared_by_table_driven_test_cases"
+	var got []string
+	for _, name := range []string{long + "_first", long + "_second", long + "_first"} {
+		t.Run(name, func(t *testing.T) {
+			got = append(got, sqltest.SQLTestName(t))
+		})
+	}
+	for _, name := range got {
+		if len(name) > 63 {
+			t.Errorf("expected name %q to be at most 63 bytes, got %d instead", name, len(name))
+		}
+		if want := "testsqltestnamelong_a_very_long"; !strings.HasPrefix(name, want) {
+			t.Errorf("expected name %q to start with %q", name, want)
+		}
+	}
+	if got[0] == got[1] {
+		t.Errorf("expected names of different subtests to be different, got %q for both", got[0])
+	}
+	// The third subtest is renamed by the testing package with a #01 suffix.
+	if got[0] == got[2] {
+		t.Errorf("expected names of subtests with the same name to be different, got %q for both", got[0])
+	}
+}
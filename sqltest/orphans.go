@@ -0,0 +1,87 @@
+package sqltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// CleanupOrphans drops the databases whose name starts with prefix and that nobody is connected to,
+// such as the temporary databases left behind by a test run that crashed, and returns their names.
+// Call it before running the tests, such as in CI, to avoid cluttering the server.
+//
+// It connects using the PostgreSQL environment variables, like Setup does with an empty connection string.
+// To avoid dropping real data, prefix must start with DatabasePrefix, and template databases,
+// the database it's connected to, and the databases in use by the tests of this process are skipped.
+// A database someone connects to while it's being dropped is skipped too.
+// It only supports PostgreSQL, as it relies on pg_stat_activity to find the databases nobody is connected to.
+func CleanupOrphans(ctx context.Context, prefix string) (dropped []string, err error) {
+	if err := checkDatabasePrefix(prefix); err != nil {
+		return nil, err
+	}
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `SELECT datname FROM pg_database d
+		WHERE left(datname, length($1)) = $1
+		AND NOT datistemplate
+		AND datname <> current_database()
+		AND NOT EXISTS (SELECT FROM pg_stat_activity a WHERE a.datname = d.datname)
+		ORDER BY datname`, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list databases: %w", err)
+	}
+	defer rows.Close()
+	var databases []string
+	for rows.Next() {
+		var database string
+		if err := rows.Scan(&database); err != nil {
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot list databases: %w", err)
+	}
+
+	for _, database := range databases {
+		if inUse(database) {
+			continue
+		}
+		_, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{database}.Sanitize())
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "55006" { // object_in_use
+			continue
+		}
+		if err != nil {
+			return dropped, fmt.Errorf("cannot drop database %q: %w", database, err)
+		}
+		dropped = append(dropped, database)
+	}
+	return dropped, nil
+}
+
+// inUse reports whether the database is reserved by a test of this process, or belongs to one of its pools.
+func inUse(database string) bool {
+	temporaryNames.mu.Lock()
+	_, ok := temporaryNames.m[database]
+	temporaryNames.mu.Unlock()
+	if ok {
+		return true
+	}
+	databasePools.mu.Lock()
+	defer databasePools.mu.Unlock()
+	for prefix := range databasePools.m {
+		if strings.HasPrefix(database, prefix+"_") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package sqltest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestCleanupOrphansPrefix(t *testing.T) {
+	t.Parallel()
+	for _, prefix := range []string{"", "production", "app_test"} {
+		// The prefix is checked before connecting to the database.
+		dropped, err := sqltest.CleanupOrphans(context.Background(), prefix)
+		if err == nil {
+			t.Errorf("expected error for prefix %q", prefix)
+		}
+		if dropped != nil {
+			t.Errorf("expected no databases to be dropped for prefix %q, got %q instead", prefix, dropped)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package sqltest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// checkOwner returns an error if Options.Owner isn't a valid role name.
+// Role names are quoted, but names that could only be used to break out of the quoted identifier are refused.
+func (o Options) checkOwner() error {
+	if o.Owner == "" {
+		return nil
+	}
+	if len(o.Owner) > maxIdentifierLength {
+		return fmt.Errorf("invalid owner %q: longer than PostgreSQL's limit of %d bytes", o.Owner, maxIdentifierLength)
+	}
+	if strings.ContainsAny(o.Owner, "\"\x00") {
+		return errors.New("invalid owner: it must not contain double quotes or NUL characters")
+	}
+	return nil
+}
+
+// ownerClause returns the clause setting the owner of the temporary database or schema,
+// or an empty string if Options.Owner isn't set.
+func (o Options) ownerClause(keyword string) string {
+	if o.Owner == "" {
+		return ""
+	}
+	return " " + keyword + " " + pgx.Identifier{o.Owner}.Sanitize()
+}
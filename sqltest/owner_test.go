@@ -0,0 +1,50 @@
+package sqltest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestOwnerInvalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc  string
+		owner string
+		want  string
+	}{
+		{
+			desc:  "quote",
+			owner: `app"; DROP DATABASE postgres; --`,
+			want:  "invalid owner: it must not contain double quotes or NUL characters",
+		},
+		{
+			desc:  "nul",
+			owner: "app\x00",
+			want:  "invalid owner: it must not contain double quotes or NUL characters",
+		},
+		{
+			desc:  "long",
+			owner: strings.Repeat("a", 64),
+			want:  "longer than PostgreSQL's limit of 63 bytes",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			opts := sqltest.Options{
+				Path:   "example/testdata/migrations",
+				Owner:  tc.owner,
+				Logger: t,
+			}
+			// Setup fails before connecting to the database.
+			_, err := sqltest.NewShared(opts).Setup(context.Background(), "")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error to contain %q, got %v instead", tc.want, err)
+			}
+		})
+	}
+}
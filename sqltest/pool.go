@@ -0,0 +1,123 @@
+package sqltest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// databasePools used by the tests of this process, keyed by the prefix of their database names.
+var databasePools = struct {
+	mu sync.Mutex // guards following
+	m  map[string]*databasePool
+}{
+	m: map[string]*databasePool{},
+}
+
+// databasePool of migrated databases reused by the tests, as configured by Options.PoolSize.
+type databasePool struct {
+	prefix string      // Prefix of the database names.
+	free   chan string // Databases ready to be used by a test.
+
+	mu        sync.Mutex // guards following
+	created   int        // Number of databases created and not discarded, up to PoolSize.
+	numbered  int        // Number of database names generated, which only ever increases.
+	discarded []string   // Names of discarded databases, reused before generating new ones.
+}
+
+// acquireDatabase for the test from the pool, waiting for one to be released if all are in use.
+// If the database wasn't used by this process yet, it's created and must be migrated.
+func (m *Migration) acquireDatabase(ctx context.Context, connString string) error {
+	hash, err := m.migrationsHash()
+	if err != nil {
+		return fmt.Errorf("cannot hash migrations: %w", err)
+	}
+	prefix := m.Options.TemporaryDatabasePrefix + DatabasePrefix + "_pool_" + hash[:16]
+
+	databasePools.mu.Lock()
+	p, ok := databasePools.m[prefix]
+	if !ok {
+		p = &databasePool{
+			prefix: prefix,
+			free:   make(chan string, m.Options.PoolSize),
+		}
+		databasePools.m[prefix] = p
+	}
+	databasePools.mu.Unlock()
+	m.databasePool = p
+
+	select {
+	case m.database = <-p.free:
+		m.premigrated = true
+		return nil
+	default:
+	}
+
+	if name, ok := p.reserve(); ok {
+		m.database = name
+		// Replace any database left behind by a previous test run, as its state is unknown.
+		if err := m.dropDB(ctx); err != nil {
+			p.discard(m.database)
+			return err
+		}
+		if err := m.cleanDB(ctx, connString); err != nil {
+			p.discard(m.database)
+			return err
+		}
+		return nil
+	}
+
+	select {
+	case m.database = <-p.free:
+		m.premigrated = true
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseDatabase truncates the tables of the database, and returns it to the pool.
+// If the database cannot be truncated, it's discarded, and created again by the next test using the pool.
+func (m *Migration) releaseDatabase(ctx context.Context) error {
+	if !m.poolReady {
+		m.databasePool.discard(m.database)
+		return nil
+	}
+	err := m.writeTx(ctx, func(tx pgx.Tx) error {
+		return truncateTables(ctx, tx, "", m.versionTable(), m.Options.Dialect)
+	})
+	if err != nil {
+		m.databasePool.discard(m.database)
+		return err
+	}
+	m.databasePool.free <- m.database
+	return nil
+}
+
+// reserve the name of a new database, unless the pool is full.
+// The name of a discarded database is reused first, so a name is never given to two databases at once.
+func (p *databasePool) reserve() (name string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.created >= cap(p.free) {
+		return "", false
+	}
+	p.created++
+	if n := len(p.discarded); n > 0 {
+		name = p.discarded[n-1]
+		p.discarded = p.discarded[:n-1]
+		return name, true
+	}
+	p.numbered++
+	return fmt.Sprintf("%s_%d", p.prefix, p.numbered), true
+}
+
+// discard a database, so another one is created in its place, reusing its name.
+func (p *databasePool) discard(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.created--
+	p.discarded = append(p.discarded, name)
+}
@@ -0,0 +1,32 @@
+package sqltest
+
+import "testing"
+
+func TestDatabasePoolReserve(t *testing.T) {
+	p := &databasePool{
+		prefix: "test_pool",
+		free:   make(chan string, 2),
+	}
+	reserve := func(want string) {
+		t.Helper()
+		name, ok := p.reserve()
+		if !ok {
+			t.Fatalf("expected %q to be reserved, but the pool is full", want)
+		}
+		if name != want {
+			t.Errorf("expected reserved name to be %q, got %q instead", want, name)
+		}
+	}
+	reserve("test_pool_1")
+	reserve("test_pool_2")
+	if name, ok := p.reserve(); ok {
+		t.Fatalf("expected full pool, got %q instead", name)
+	}
+
+	// The name of a discarded database is reused, while the other one is still in use.
+	p.discard("test_pool_1")
+	reserve("test_pool_1")
+	if name, ok := p.reserve(); ok {
+		t.Fatalf("expected full pool, got %q instead", name)
+	}
+}
@@ -0,0 +1,213 @@
+package sqltest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// SharedMigration sets up a single database from TestMain, shared by all tests of a package,
+// with each test isolated in its own transaction using SetupTx.
+//
+// Compared to a database for each test with New, the migrations run once per package,
+// which is much faster when there are many tests or migrations. The tradeoffs are:
+//
+//   - Tests are only isolated by their transaction, so the code under test must use it,
+//     and must not commit it. Changes made with the pool are seen by every test that runs afterwards.
+//   - Sequences aren't transactional, so generated IDs depend on the tests that ran before.
+//   - Concurrent transactions can block each other on row locks and unique constraints,
+//     which isn't an issue with separate databases.
+//   - Statements that cannot run inside a transaction, such as VACUUM or CREATE INDEX CONCURRENTLY,
+//     cannot be tested.
+//
+// Use New for the tests that don't fit these constraints, alongside the shared database.
+//
+// Example:
+//
+//	var shared = sqltest.NewShared(sqltest.Options{Path: "testdata/migrations"})
+//
+//	func TestMain(m *testing.M) {
+//		if _, err := shared.Setup(context.Background(), ""); err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		if err := shared.Teardown(); err != nil {
+//			log.Print(err)
+//			code = 1
+//		}
+//		os.Exit(code)
+//	}
+//
+//	func TestPosts(t *testing.T) {
+//		tx := shared.SetupTx(context.Background(), t)
+//		// ...
+//	}
+type SharedMigration struct {
+	m  *Migration
+	tb *sharedTB
+}
+
+// NewShared migration to use with the tests of a package, set up from TestMain.
+//
+// The name of the database is derived from the directory of the package, as tests of
+// different packages run concurrently, unless Options.NameFunc is set.
+// Options.KeepOnFailure isn't supported, as the outcome of the tests is unknown to Teardown;
+// set the SQLTEST_KEEP environment variable to keep the database instead.
+//
+// Without Options.Logger, it logs to the standard logger of the log package.
+func NewShared(o Options) *SharedMigration {
+	tb := &sharedTB{}
+	return &SharedMigration{
+		m:  New(tb, o),
+		tb: tb,
+	}
+}
+
+// Setup the shared database, and return a pgx pool connected to it, as Migration.Setup does.
+// Unlike Migration.Setup, which calls t.Fatal, it returns an error if something fails,
+// after cleaning up what was created.
+func (s *SharedMigration) Setup(ctx context.Context, connString string) (pool *pgxpool.Pool, err error) {
+	if s.tb.name == "" {
+		if s.tb.name, err = sharedName(); err != nil {
+			return nil, err
+		}
+	}
+	err = s.tb.run(func() {
+		pool = s.m.Setup(ctx, connString)
+	})
+	if err != nil {
+		if cleanupErr := s.Teardown(); cleanupErr != nil {
+			err = fmt.Errorf("%w (cannot clean up: %v)", err, cleanupErr)
+		}
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Teardown the shared database, dropping it unless Options.SkipTeardown is set.
+// Call it from TestMain after running the tests.
+func (s *SharedMigration) Teardown() error {
+	return s.tb.cleanup()
+}
+
+// SetupTx begins a transaction on the shared database for the test,
+// which is rolled back automatically during testing cleanup.
+// Every test sees the database as it was after the migrations, as long as nothing is committed.
+func (s *SharedMigration) SetupTx(ctx context.Context, t testing.TB) pgx.Tx {
+	t.Helper()
+	if s.m.pool == nil {
+		t.Fatal("cannot begin transaction: Setup must be called first")
+	}
+	return beginTx(ctx, t, s.m.pool)
+}
+
+// DatabaseName returns the name of the database created by Setup.
+//
+// It panics if called before Setup.
+func (s *SharedMigration) DatabaseName() string {
+	return s.m.DatabaseName()
+}
+
+// sharedName returns the name of the database shared by the tests of the package
+// in the working directory, which go test sets to the directory of the package.
+// It starts with DatabasePrefix, like the names of the temporary databases of tests.
+func sharedName() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("cannot get working directory: %w", err)
+	}
+	base := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(filepath.Base(wd)))
+	// Directories with the same name in different modules mustn't share a database.
+	h := sha256.Sum256([]byte(wd))
+	return DatabasePrefix + "_shared_" + base + "_" + hex.EncodeToString(h[:])[:8], nil
+}
+
+// errSharedFailNow is raised by sharedTB when the migration fails, and recovered by run.
+var errSharedFailNow = errors.New("sqltest: shared migration failed")
+
+// sharedTB implements the methods of testing.TB used by Migration outside of a test,
+// turning failures into errors.
+type sharedTB struct {
+	// TB is nil, so calling a method that isn't implemented below panics.
+	testing.TB
+
+	name     string
+	errs     []string
+	cleanups []func()
+}
+
+// run fn, and return an error if it failed.
+func (tb *sharedTB) run(fn func()) (err error) {
+	tb.errs = nil
+	defer func() {
+		if r := recover(); r != nil && r != errSharedFailNow {
+			panic(r)
+		}
+		if len(tb.errs) > 0 {
+			err = errors.New(strings.Join(tb.errs, "; "))
+		}
+	}()
+	fn()
+	return nil
+}
+
+// cleanup runs the registered functions in last added, first called order, as testing does.
+func (tb *sharedTB) cleanup() error {
+	var errs []string
+	for len(tb.cleanups) > 0 {
+		fn := tb.cleanups[len(tb.cleanups)-1]
+		tb.cleanups = tb.cleanups[:len(tb.cleanups)-1]
+		if err := tb.run(fn); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (tb *sharedTB) Cleanup(fn func()) { tb.cleanups = append(tb.cleanups, fn) }
+func (tb *sharedTB) Failed() bool      { return len(tb.errs) > 0 }
+func (tb *sharedTB) Helper()           {}
+func (tb *sharedTB) Name() string      { return tb.name }
+
+func (tb *sharedTB) Log(args ...interface{})                 { log.Print(args...) }
+func (tb *sharedTB) Logf(format string, args ...interface{}) { log.Printf(format, args...) }
+
+func (tb *sharedTB) Error(args ...interface{}) { tb.errs = append(tb.errs, fmt.Sprint(args...)) }
+func (tb *sharedTB) Errorf(format string, args ...interface{}) {
+	tb.errs = append(tb.errs, fmt.Sprintf(format, args...))
+}
+
+func (tb *sharedTB) FailNow() {
+	if len(tb.errs) == 0 {
+		tb.errs = append(tb.errs, "failed")
+	}
+	panic(errSharedFailNow)
+}
+
+func (tb *sharedTB) Fatal(args ...interface{}) {
+	tb.Error(args...)
+	tb.FailNow()
+}
+
+func (tb *sharedTB) Fatalf(format string, args ...interface{}) {
+	tb.Errorf(format, args...)
+	tb.FailNow()
+}
@@ -0,0 +1,34 @@
+package sqltest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestSharedSetupError(t *testing.T) {
+	t.Parallel()
+	shared := sqltest.NewShared(sqltest.Options{
+		Path:           "example/testdata/migrations",
+		Host:           "127.0.0.1",
+		Port:           1, // Nothing should be listening on this port.
+		ConnectTimeout: 100 * time.Millisecond,
+		Logger:         t,
+	})
+	pool, err := shared.Setup(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected error connecting to the database")
+	}
+	if pool != nil {
+		t.Errorf("expected pool to be nil, got %v instead", pool)
+	}
+	if want := "127.0.0.1"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error to contain %q, got %q instead", want, err)
+	}
+	if err := shared.Teardown(); err != nil {
+		t.Errorf("unexpected teardown error: %v", err)
+	}
+}
@@ -3,10 +3,21 @@ package sqltest
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/tern/migrate"
@@ -17,7 +28,8 @@ var (
 	// It is used to mitigate the risk of running migration and tests on the wrong database.
 	DatabasePrefix = "test"
 
-	// SchemaVersionTable where tern saves the version of the current migration in PostgreSQL.
+	// SchemaVersionTable where tern saves the version of the current migration in PostgreSQL,
+	// unless Options.VersionTable is set.
 	SchemaVersionTable = "schema_version"
 )
 
@@ -34,6 +46,11 @@ type Options struct {
 	// Force clean the database if it's dirty.
 	Force bool
 
+	// ForceReset drops the temporary database or schema of the test if it already exists,
+	// such as when left behind by a previous test run that crashed, and creates it again.
+	// Unlike Force, it doesn't affect existing databases used with UseExisting.
+	ForceReset bool
+
 	// SkipTeardown stops the Teardown function being registered with testing cleanup.
 	// You can use this to debug migration after running a specific test.
 	//
@@ -43,6 +60,11 @@ type Options struct {
 
 	// UseExisting database from connection instead of creating a temporary one.
 	// If set, the database isn't dropped after the tests.
+	//
+	// The checksums of the applied migrations are saved to the schema_version_checksums table,
+	// named after the version table, and Setup fails if an applied migration file was modified since,
+	// even if Teardown migrated the database back down. Delete the row of the migration from that table
+	// to accept the change.
 	UseExisting bool
 
 	// TemporaryDatabasePrefix for namespacing the temporary database name created for the test function.
@@ -50,10 +72,289 @@ type Options struct {
 	// Ignore if using UseExisting.
 	TemporaryDatabasePrefix string
 
+	// NameFunc returns the name of the temporary database or schema of the test,
+	// which is prefixed with TemporaryDatabasePrefix.
+	// If nil, SQLTestName is used.
+	//
+	// Names longer than PostgreSQL's limit of 63 bytes are shortened deterministically with a hash suffix,
+	// and Setup fails if the name is already used by another test of the process.
+	NameFunc func(t testing.TB) string
+
+	// VersionTable where tern saves the version of the current migration, instead of SchemaVersionTable,
+	// such as when a table with the same name already exists in a database used with UseExisting.
+	// It can be qualified with a schema, as in "migrations.schema_version", and the schema must exist.
+	// It's ignored when using a custom Runner, which tracks the version itself.
+	VersionTable string
+
 	// Path to the migration files.
+	//
+	// Migration files can use tern's format, where the down migration follows
+	// the "---- create above / drop below ----" line in the same file,
+	// or golang-migrate's format, where up and down migrations are in
+	// separate files, such as 001_posts.up.sql and 001_posts.down.sql.
+	//
+	// If FS is set, Path is the directory containing the migrations in FS,
+	// and defaults to its root directory.
 	Path string
+
+	// FS to read the migration files from, such as an embed.FS.
+	// If nil, migrations are read from Path in the operating system's filesystem,
+	// relative to the working directory of the test.
+	//
+	// Embedding the migrations makes tests independent of the directory they're run from:
+	//
+	//	//go:embed migrations/*.sql
+	//	var migrations embed.FS
+	//
+	//	migration := sqltest.New(t, sqltest.Options{FS: migrations, Path: "migrations"})
+	FS fs.FS
+
+	// Paths to additional directories with migration files, such as when a shared library
+	// ships base migrations and each service adds its own on top.
+	//
+	// The migrations in Path and Paths are merged, and applied in the order of their file names
+	// across all directories, so their versions must not overlap.
+	// Setup fails if the same version is found in more than one directory.
+	// Subdirectories, such as those containing tern's shared templates, aren't supported with Paths.
+	Paths []string
+
+	// RunDownMigrations during Teardown, before the temporary database is dropped.
+	// Use it to verify your down migrations work, as they're otherwise never exercised.
+	//
+	// This is only required for golang-migrate style migrations, as down migrations
+	// using tern's format always run.
+	// When set, Setup fails if a down migration is missing.
+	RunDownMigrations bool
+
+	// TemplateData is the data the migration files are executed with as text/template templates,
+	// such as a map or a struct holding a tablespace or schema name that differs between environments:
+	//
+	//	CREATE TABLE posts (...) TABLESPACE {{.Tablespace}};
+	//
+	// Files without template actions are unchanged. Files using tern's format are always executed as templates
+	// by tern, which can also use its shared templates and sprig functions, while golang-migrate style files
+	// are only executed as templates when TemplateData is set. A struct is converted to a map of its exported
+	// fields, so its methods aren't available.
+	//
+	// Values are written as is, without any escaping, so a value containing a quote or a semicolon
+	// changes the SQL that runs: only use values you control, such as identifiers from the test configuration,
+	// and never values coming from user input.
+	// It's ignored when using a custom Runner.
+	TemplateData interface{}
+
+	// Seed contains paths to SQL files with fixture data.
+	// They are executed in order after the migrations are applied, before Setup returns.
+	// As the temporary database is recreated for each test, seeds run on every Setup.
+	Seed []string
+
+	// UseTemplate to migrate a template database once, and create the temporary database
+	// of each test from it with CREATE DATABASE ... TEMPLATE, instead of migrating it.
+	// This is considerably faster when you have many tests or migrations.
+	//
+	// The template database is named after a hash of the migration files, so it's recreated
+	// when they change. It isn't dropped after the tests, and is reused by other test binaries
+	// and later test runs, unless Force or ForceReset is set, so running the tests of a package again
+	// doesn't migrate a database at all. A template is only reused if it was fully migrated,
+	// as it's marked as a template once its migrations succeed.
+	// A PostgreSQL advisory lock ensures it's only created once when test binaries of multiple packages
+	// start at the same time, such as with go test ./...
+	// Ignored if using UseExisting.
+	UseTemplate bool
+
+	// UseSchema to create a temporary schema in the database from connection instead of
+	// creating a temporary database, and set it as the search_path of the connections.
+	// Migrations are applied to the schema, which is dropped with CASCADE after the tests.
+	//
+	// This is useful when you cannot create databases, such as in a locked-down CI environment.
+	// The schema is named like a temporary database, and TemporaryDatabasePrefix also applies to it.
+	// Migrations must not set the schema of the objects they create explicitly.
+	UseSchema bool
+
+	// Owner role of the temporary database, or of the temporary schema when using UseSchema,
+	// such as when the connection uses a superuser, but the tests must run as a restricted role.
+	// The connection user must be a member of the role, or a superuser.
+	//
+	// Migrations still run as the connection user, so the objects they create are owned by it;
+	// use BeforeMigrate to SET ROLE if they must be owned by the role too,
+	// and AfterConnect to SET ROLE for the connections of the tests.
+	// Setup fails if the name contains a double quote or a NUL character.
+	// Ignored if using UseExisting.
+	Owner string
+
+	// Template, Encoding, and Locale of the temporary database, set by the TEMPLATE, ENCODING,
+	// and LOCALE clauses of CREATE DATABASE, such as to test an ORDER BY depending on the collation.
+	// Unset options are left out, so the database inherits the settings of the server default template,
+	// usually template1.
+	//
+	// Locale sets both LC_COLLATE and LC_CTYPE, and requires PostgreSQL 13 or later.
+	// A different encoding or locale than template1 usually requires template0 as the Template:
+	//
+	//	sqltest.Options{Template: "template0", Encoding: "UTF8", Locale: "C"}
+	//
+	// When using UseTemplate, they apply to the migrated template database, which the temporary databases are copied from.
+	// Ignored if using UseExisting or UseSchema.
+	Template string
+	Encoding string
+	Locale   string
+
+	// KeepOnFailure skips dropping the temporary database or schema during Teardown
+	// if the test failed, and logs its name, so you can inspect it manually.
+	// Down migrations aren't run either, to preserve the state that caused the failure.
+	//
+	// Set the SQLTEST_KEEP environment variable to a non-empty value to keep them even if
+	// the tests pass, such as during a debugging session.
+	// You must use the Force option to run the tests again after keeping the database.
+	KeepOnFailure bool
+
+	// Persist the temporary database of the test across test runs, so you can inspect it with psql
+	// between iterations during local development. It's a convenience for development only,
+	// and mustn't be used in CI, where every run must start from a clean database.
+	//
+	// The database has the deterministic name of the test, as returned by NameFunc.
+	// It's created and migrated if it doesn't exist, and reused if it does, applying only the
+	// migrations that weren't applied yet. It's never dropped, and down migrations aren't run.
+	// Unlike UseExisting, sqltest still creates the database; like UseExisting, Setup fails if
+	// a migration file was modified after it was applied. Use Force to drop it and start over.
+	//
+	// AfterMigrate and Seed only run when the database is created.
+	// CleanupOrphans drops persisted databases too, as they aren't in use by a test.
+	// UseTemplate and PoolSize are ignored when Persist is set, and Persist is ignored if using
+	// UseExisting or UseSchema.
+	Persist bool
+
+	// SingleTransaction applies all migrations in a single transaction, instead of a transaction
+	// for each migration file, so a failing migration doesn't leave the database partially migrated.
+	//
+	// Statements that cannot run inside a transaction block, such as CREATE INDEX CONCURRENTLY, cannot be used,
+	// and Setup fails before applying the migrations if one uses CONCURRENTLY.
+	// It's ignored when using a custom Runner.
+	SingleTransaction bool
+
+	// Dialect of the database server, which is Postgres by default.
+	// Set it to Cockroach to avoid the SQL CockroachDB doesn't support, which requires a custom Runner.
+	Dialect Dialect
+
+	// AdminConn is used to create and drop the temporary database or schema, and the template database,
+	// instead of opening a new connection with the connection string passed to Setup.
+	// Use it to reuse a privileged connection, such as to avoid hitting connection limits with many parallel tests.
+	//
+	// It must be connected to a database other than the temporary ones, and must not be in a transaction,
+	// as CREATE DATABASE cannot run inside a transaction block. With UseSchema, the schema is created
+	// in the database it's connected to.
+	// Tests sharing the connection take turns using it, as a connection cannot be used concurrently.
+	// sqltest doesn't close it, as it didn't open it.
+	AdminConn *pgx.Conn
+
+	// ReadOnly makes the connections of the pool returned by Setup read-only once the database is set up,
+	// by setting default_transaction_read_only, so that tests can verify code paths don't attempt writes,
+	// which fail with a read_only_sql_transaction error.
+	// Migrations, BeforeMigrate, AfterMigrate, and Seed run with write access before it's engaged,
+	// and Truncate and ResetSequences still work, as they use read-write transactions.
+	//
+	// It only catches accidental writes, as a transaction can still be started with BEGIN READ WRITE.
+	ReadOnly bool
+
+	// VerifyIdempotent applies the up migrations a second time once they're applied, and fails the test
+	// if one of them fails, to verify they can be safely applied again, such as with blue/green deployments.
+	// Only use it if your migrations are written to be idempotent, such as with IF NOT EXISTS.
+	// It's ignored when using a custom Runner.
+	VerifyIdempotent bool
+
+	// Runner applies the migrations instead of the built-in implementation using tern,
+	// so you can use other tools such as goose or golang-migrate, and their own version tables.
+	//
+	// The Force and RunDownMigrations options are ignored when using a custom runner.
+	Runner MigrationRunner
+
+	// Host, Port, User, Password, and SSLMode of the PostgreSQL server,
+	// overriding the connection string passed to Setup and the PostgreSQL environment variables.
+	// This lets you point a test suite to a different server without changing the environment
+	// of the whole process. Unset fields are ignored.
+	//
+	// See Options.ConnString for the order of precedence of the connection settings.
+	Host     string
+	Port     uint16
+	User     string
+	Password string
+	SSLMode  string
+
+	// SSLRootCert is the path to the file with the certificate authorities used to verify the certificate
+	// of the server, such as when a managed PostgreSQL server requires the verify-full SSLMode.
+	// Like the other connection settings, it overrides the sslrootcert setting of the connection string,
+	// and the PGSSLROOTCERT environment variable.
+	// The connection settings apply to every connection of Setup, including the ones used to create
+	// and drop the temporary database.
+	SSLRootCert string
+
+	// ConnectTimeout for each attempt to establish the initial connection to the database.
+	// If zero, the connect_timeout setting from the connection string or the PGCONNECT_TIMEOUT
+	// environment variable is used.
+	ConnectTimeout time.Duration
+
+	// ConnectRetries is the number of times Setup retries establishing the initial connection
+	// to the database, with exponential backoff, before failing with the last error.
+	// This is useful when the database might not be ready yet when the tests start, such as in CI.
+	ConnectRetries int
+
+	// PoolSize of migrated databases reused by the tests, instead of creating a temporary database
+	// for each test. Up to PoolSize databases are created, and Setup waits for a database to be
+	// released by a test if all of them are in use.
+	// When a test is done, all tables except the version table are truncated with
+	// RESTART IDENTITY CASCADE, and the database is returned to the pool. Down migrations aren't run.
+	//
+	// Pools are shared by the tests of a test binary using the same migrations and TemporaryDatabasePrefix,
+	// and the size of a pool is defined by the first test using it.
+	// The databases aren't dropped after the tests, and are recreated on the next run.
+	// Use a different TemporaryDatabasePrefix for each package if their tests run in parallel.
+	// Ignored if using UseExisting or UseSchema.
+	PoolSize int
+
+	// BeforeMigrate is called by Setup once the temporary database or schema is created,
+	// and before any migration runs, with a connection to the database.
+	// Use it to create what the migrations assume exists, such as extensions or roles.
+	// If it returns an error, the test fails.
+	//
+	// When using UseTemplate, it's called once for the template database, before migrating it,
+	// and databases reused from the pool with PoolSize aren't migrated again, so it isn't called either.
+	BeforeMigrate func(ctx context.Context, conn *pgx.Conn) error
+
+	// AfterMigrate is called once by Setup after the migrations are applied, and before Seed,
+	// with a connection to the database.
+	// Use it to prepare the database with Go code, such as application helpers.
+	// If it returns an error, the test fails.
+	AfterMigrate func(ctx context.Context, conn *pgx.Conn) error
+
+	// Logger for what sqltest does, such as the name of each migration file as it's applied,
+	// and the statement that failed if a migration fails.
+	// If nil, the test's Logf method is used.
+	//
+	// Migration files are applied at once, but when one fails, its statements are executed
+	// one at a time in a transaction that is rolled back, to log the line and index of the
+	// statement that failed. Semicolons in strings, dollar-quoted function bodies, and comments
+	// don't split a statement.
+	Logger Logger
+
+	// AfterConnect is called on every connection of the pool returned by Setup,
+	// such as to register custom data types with pgx.
+	// If it returns an error, the connection is discarded.
+	//
+	// Unlike AfterMigrate, which is called once to prepare the database, AfterConnect is called
+	// to prepare each connection, as pgx type registrations are per connection.
+	// It's only called once the migrations are applied and Seed is done,
+	// so the types created by the migrations already exist.
+	AfterConnect func(ctx context.Context, conn *pgx.Conn) error
+}
+
+// MigrationRunner applies migrations to a database.
+type MigrationRunner interface {
+	// Run the migrations from source on the database.
+	// The source is the directory containing the migrations, read from Options.FS or Options.Path.
+	Run(ctx context.Context, conn *pgx.Conn, source fs.FS) error
 }
 
+// keepEnv is the environment variable to keep the temporary databases and schemas after the tests.
+const keepEnv = "SQLTEST_KEEP"
+
 // Migration simplifies avlidadting the migration process, and setting up a test database
 // for executing your PostgreSQL-based tests on.
 type Migration struct {
@@ -62,6 +363,37 @@ type Migration struct {
 	t        testing.TB
 	migrator *migrate.Migrator
 
+	// downMigrations is set if the migrations should be undone during Teardown.
+	downMigrations bool
+
+	// template database the temporary database was created from.
+	template string
+
+	// premigrated is set if the database was created already migrated, such as from the template database.
+	premigrated bool
+
+	// persisted is set if the database was left by a previous test run when using Persist.
+	persisted bool
+
+	// databasePool the database was acquired from, if PoolSize is used.
+	databasePool *databasePool
+
+	// poolReady is set once the database acquired from the pool is migrated and can be reused.
+	poolReady bool
+
+	// schema created when using UseSchema.
+	schema string
+
+	// merged migrations from Path and Paths, if Paths is used.
+	merged fs.FS
+
+	// ready is set to 1 once the database is set up, and AfterConnect can be called.
+	ready int32
+
+	// setupConn used by Setup to apply the migrations, set if its session was changed
+	// by AfterConnect or ReadOnly afterwards.
+	setupConn *pgx.Conn
+
 	pool     *pgxpool.Pool
 	conn     *pgx.Conn
 	database string
@@ -78,20 +410,41 @@ type Migration struct {
 //
 // If you're using PostgreSQL environment variables, you should pass an empty string as the
 // connection string, as in:
-// 	pool := m.Setup(context.Background(), "")
+//
+//	pool := m.Setup(context.Background(), "")
 //
 // Reference for configuring the PostgreSQL client with environment variables:
 // https://www.postgresql.org/docs/current/libpq-envars.html
 //
 // Reference for using connString:
 // https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+//
+// Connection settings from the options, such as Host and User, take precedence over connString.
+//
+// The context is used by every connection and query of Setup, including the migrations,
+// so you can use a context with a deadline to fail a test instead of hanging on a stuck migration.
+// Teardown doesn't use it, so the database is cleaned up even if the context was canceled.
 func (m *Migration) Setup(ctx context.Context, connString string) *pgxpool.Pool {
 	if m.t == nil {
 		panic("migration must be initialized with sqltest.New()")
 	}
 
 	m.t.Helper()
-	m.t.Log("setup PostgreSQL database")
+	m.logf("setup PostgreSQL database")
+
+	if err := m.Options.checkDialect(); err != nil {
+		m.t.Fatal(err)
+	}
+	if err := m.Options.checkOwner(); err != nil {
+		m.t.Fatal(err)
+	}
+	if _, err := templateData(m.Options.TemplateData); err != nil {
+		m.t.Fatal(err)
+	}
+	connString, err := m.Options.ConnString(connString)
+	if err != nil {
+		m.t.Fatal(err)
+	}
 
 	// Similarly to how it's done in the application code, pgxpool is used to create a pool
 	// of connections to the database that is safe to be used concurrently.
@@ -99,26 +452,101 @@ func (m *Migration) Setup(ctx context.Context, connString string) *pgxpool.Pool
 	if err != nil {
 		m.t.Fatal(err)
 	}
+	if m.Options.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = m.Options.ConnectTimeout
+	}
+	if m.Options.AfterConnect != nil || m.Options.ReadOnly {
+		poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if atomic.LoadInt32(&m.ready) == 0 {
+				return nil
+			}
+			return m.afterConnect(ctx, conn)
+		}
+	}
 
-	if !m.Options.UseExisting {
-		var err error
-		if m.conn, err = pgx.Connect(ctx, connString); err != nil {
+	if len(m.Options.Paths) > 0 {
+		if m.merged, err = m.mergeMigrations(); err != nil {
+			m.t.Fatalf("cannot load migrations: %v", err)
+		}
+	}
+
+	switch {
+	case m.Options.UseSchema:
+		if m.conn, err = m.openAdmin(ctx, connString); err != nil {
 			m.t.Fatal(err)
 		}
-		m.database = m.Options.TemporaryDatabasePrefix + SQLTestName(m.t)
+		// Check the database name before creating the schema, as it's not a temporary database.
+		var database string
+		if err := m.adminQueryRow(ctx, &database, "SELECT current_database();"); err != nil {
+			m.t.Fatalf("cannot get database name: %v", err)
+		}
+		if err := checkDatabasePrefix(database); err != nil {
+			m.t.Fatal(err)
+		}
+		m.schema = m.temporaryName()
+		if err := m.reserveName(m.schema); err != nil {
+			m.t.Fatal(err)
+		}
+		if strings.ContainsAny(m.schema, `" `) {
+			m.t.Fatalf("invalid schema name")
+		}
+		if err := m.createSchema(ctx); err != nil {
+			m.t.Fatalf("cannot create schema: %v", err)
+		}
+		poolConfig.ConnConfig.RuntimeParams["search_path"] = m.schema
+	case !m.Options.UseExisting:
+		if m.conn, err = m.openAdmin(ctx, connString); err != nil {
+			m.t.Fatal(err)
+		}
+		m.database = m.temporaryName()
 		// Lousy check if database name is invalid.
 		// Ref: https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
 		if strings.ContainsAny(m.database, `" `) {
 			m.t.Fatalf("invalid database name")
 		}
+		// Check the name before creating the database, so it isn't left behind if the check fails.
+		if err := checkDatabasePrefix(m.database); err != nil {
+			m.t.Fatal(err)
+		}
+		if m.Options.PoolSize <= 0 || m.Options.Persist {
+			if err := m.reserveName(m.database); err != nil {
+				m.t.Fatal(err)
+			}
+		}
 
-		if err := m.cleanDB(ctx, connString); err != nil {
-			m.t.Fatalf("cannot create database: %v", err)
+		if m.Options.UseTemplate && !m.Options.Persist {
+			if m.template, err = m.ensureTemplate(ctx, connString); err != nil {
+				m.t.Fatalf("cannot create template database: %v", err)
+			}
+			m.premigrated = true
+		}
+		switch {
+		case m.Options.Persist:
+			if err := m.persistDB(ctx); err != nil {
+				m.t.Fatalf("cannot create database: %v", err)
+			}
+		case m.Options.PoolSize > 0:
+			if err := m.acquireDatabase(ctx, connString); err != nil {
+				m.t.Fatalf("cannot acquire database from pool: %v", err)
+			}
+			m.t.Cleanup(func() {
+				// Discard the database if it isn't released by Teardown, such as when Setup fails.
+				if m.databasePool != nil {
+					m.databasePool.discard(m.database)
+				}
+			})
+		default:
+			if err := m.cleanDB(ctx, connString); err != nil {
+				m.t.Fatalf("cannot create database: %v", err)
+			}
 		}
 
 		poolConfig.ConnConfig.Database = m.database
 	}
-	m.pool, err = pgxpool.ConnectConfig(ctx, poolConfig)
+	err = m.retry(ctx, func() (err error) {
+		m.pool, err = pgxpool.ConnectConfig(ctx, poolConfig)
+		return err
+	})
 	if err != nil {
 		m.t.Fatalf("cannot connect to database: %v", err)
 	}
@@ -133,9 +561,8 @@ func (m *Migration) Setup(ctx context.Context, connString string) *pgxpool.Pool
 		m.t.Fatalf("cannot get database name: %v", err)
 	}
 
-	// Enforce database name to start with "test" to mitigate risk of modifying wrong database by mistake.
-	if !strings.HasPrefix(m.database, DatabasePrefix) {
-		m.t.Fatalf(`refusing to run integration tests: database name is %q (%q prefix is required)`, m.database, DatabasePrefix)
+	if err := checkDatabasePrefix(m.database); err != nil {
+		m.t.Fatal(err)
 	}
 
 	if !m.Options.SkipTeardown {
@@ -146,43 +573,495 @@ func (m *Migration) Setup(ctx context.Context, connString string) *pgxpool.Pool
 	if err := m.migrate(ctx, poolConn); err != nil {
 		m.t.Fatal(err)
 	}
+	m.poolReady = m.databasePool != nil
+	// A persisted database already has what AfterMigrate and Seed added when it was created.
+	if !m.persisted {
+		if m.Options.AfterMigrate != nil {
+			if err := m.Options.AfterMigrate(ctx, poolConn.Conn()); err != nil {
+				m.t.Fatalf("AfterMigrate failed: %v", err)
+			}
+		}
+		for _, path := range m.Options.Seed {
+			if err := seed(ctx, poolConn, path); err != nil {
+				m.t.Fatalf("cannot seed database with %s: %v", path, err)
+			}
+		}
+	}
+	if m.Options.AfterConnect != nil || m.Options.ReadOnly {
+		atomic.StoreInt32(&m.ready, 1)
+		// The connection used to set up the database was created before it was ready.
+		if err := m.afterConnect(ctx, poolConn.Conn()); err != nil {
+			m.t.Fatal(err)
+		}
+		m.setupConn = poolConn.Conn()
+	}
 	return m.pool
 }
 
+// afterConnect prepares a connection of the pool once the database is set up.
+func (m *Migration) afterConnect(ctx context.Context, conn *pgx.Conn) error {
+	if m.Options.AfterConnect != nil {
+		if err := m.Options.AfterConnect(ctx, conn); err != nil {
+			return fmt.Errorf("AfterConnect failed: %w", err)
+		}
+	}
+	if m.Options.ReadOnly {
+		if _, err := conn.Exec(ctx, "SET default_transaction_read_only = on"); err != nil {
+			return fmt.Errorf("cannot make connection read-only: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeTx calls fn in a read-write transaction on the pool, even if ReadOnly is set,
+// and commits it if fn doesn't return an error.
+func (m *Migration) writeTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := m.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DatabaseName returns the name of the database created by Setup for the test.
+// If UseExisting or UseSchema is set, the name of the existing database is returned instead.
+//
+// It panics if called before Setup.
+func (m *Migration) DatabaseName() string {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	if m.pool == nil {
+		panic("cannot get database name: Setup must be called first")
+	}
+	return m.database
+}
+
+// Connect opens a new connection to the database of the test, in addition to the pool returned by Setup,
+// such as to test advisory locks or LISTEN/NOTIFY with connections you control.
+// The connection uses the same settings as the pool, including AfterConnect and ReadOnly,
+// and is closed during testing cleanup, before Teardown runs.
+// If something fails, t.Fatal is called.
+func (m *Migration) Connect(ctx context.Context) *pgx.Conn {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	if m.pool == nil {
+		m.t.Fatal("cannot connect to database: Setup must be called first")
+	}
+	conn, err := m.openConn(ctx)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.t.Cleanup(func() {
+		conn.Close(context.Background())
+	})
+	return conn
+}
+
+// WaitForNotification opens a dedicated connection to the database of the test, listens on channel,
+// and blocks until a notification is received or ctx is done, such as to test a trigger calling pg_notify.
+// The connection is closed before it returns.
+//
+// Notifications sent before the channel is listened to are missed, so when calling it from a goroutine
+// before running the code under test, send the notification again until it's received,
+// or use Connect to LISTEN before running the code under test.
+func (m *Migration) WaitForNotification(ctx context.Context, channel string) (*pgconn.Notification, error) {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	if m.pool == nil {
+		return nil, errors.New("cannot wait for notification: Setup must be called first")
+	}
+	conn, err := m.openConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return nil, fmt.Errorf("cannot listen on channel %q: %w", channel, err)
+	}
+	n, err := conn.WaitForNotification(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wait for notification on channel %q: %w", channel, err)
+	}
+	return n, nil
+}
+
+// openConn opens a new connection to the database of the test, with the settings of the pool.
+func (m *Migration) openConn(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, m.pool.Config().ConnConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to database: %w", err)
+	}
+	if atomic.LoadInt32(&m.ready) == 1 {
+		if err := m.afterConnect(ctx, conn); err != nil {
+			conn.Close(context.Background())
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// AppliedVersions returns the versions of the migrations applied by Setup, in order,
+// read from the migration file names, such as 1 for 001_posts.sql.
+// You can use it to check the expected migrations ran, such as to guard against a missing file.
+//
+// It is not supported when using a custom Runner.
+func (m *Migration) AppliedVersions() []int {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	if m.pool == nil {
+		m.t.Fatal("cannot get applied versions: Setup must be called first")
+	}
+	if m.migrator == nil {
+		m.t.Fatal("cannot get applied versions: not supported with a custom Runner")
+	}
+	var current int32
+	if err := m.pool.QueryRow(context.Background(), "SELECT version FROM "+m.versionTable()).Scan(&current); err != nil {
+		m.t.Fatalf("cannot get schema version: %v", err)
+	}
+	if int(current) > len(m.migrator.Migrations) {
+		m.t.Fatalf("schema version %d is greater than the number of migrations (%d)", current, len(m.migrator.Migrations))
+	}
+	versions := make([]int, 0, current)
+	for _, mig := range m.migrator.Migrations[:current] {
+		matches := versionPattern.FindStringSubmatch(mig.Name)
+		if matches == nil {
+			m.t.Fatalf("cannot get version of migration %q", mig.Name)
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			m.t.Fatalf("cannot get version of migration %q: %v", mig.Name, err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// maxConnectBackoff is the maximum time to wait between attempts to connect to the database.
+const maxConnectBackoff = 5 * time.Second
+
+// connect to the database, retrying as configured by the options.
+func (m *Migration) connect(ctx context.Context, connString string) (conn *pgx.Conn, err error) {
+	config, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	if m.Options.ConnectTimeout > 0 {
+		config.ConnectTimeout = m.Options.ConnectTimeout
+	}
+	err = m.retry(ctx, func() (err error) {
+		conn, err = pgx.ConnectConfig(ctx, config)
+		return err
+	})
+	return conn, err
+}
+
+// retry connecting to the database with exponential backoff up to ConnectRetries times.
+func (m *Migration) retry(ctx context.Context, connect func() error) error {
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil || attempt > m.Options.ConnectRetries {
+			return err
+		}
+		m.logf("cannot connect to database (attempt %d of %d): %v", attempt, m.Options.ConnectRetries+1, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+// SetupTx begins a transaction on the database set up by Setup,
+// which is rolled back automatically during testing cleanup.
+// This is the "transaction per test" pattern: every test sees the database as it was after the migrations,
+// without the cost of creating a database.
+//
+// Caveat: the code under test must use the returned transaction instead of the pool,
+// and must not commit it. If it manages its own transactions, use Savepoint.
+func (m *Migration) SetupTx(ctx context.Context) pgx.Tx {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	if m.pool == nil {
+		m.t.Fatal("cannot begin transaction: Setup must be called first")
+	}
+	return beginTx(ctx, m.t, m.pool)
+}
+
+// Savepoint begins a pseudo nested transaction on tx using a savepoint,
+// which is rolled back automatically during testing cleanup.
+// Committing it releases the savepoint, but changes are still rolled back with tx.
+func (m *Migration) Savepoint(ctx context.Context, tx pgx.Tx) pgx.Tx {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	return beginTx(ctx, m.t, tx)
+}
+
+// Truncate all tables of the database, except for the version table, with RESTART IDENTITY CASCADE,
+// or only CASCADE with the Cockroach dialect.
+// If UseSchema is set, only the tables of the temporary schema are truncated.
+//
+// It is a cheap way to reset the data between subtests sharing the database set up by Setup,
+// without running the migrations again.
+func (m *Migration) Truncate(ctx context.Context) {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	if m.pool == nil {
+		m.t.Fatal("cannot truncate tables: Setup must be called first")
+	}
+	err := m.writeTx(ctx, func(tx pgx.Tx) error {
+		return truncateTables(ctx, tx, m.schema, m.versionTable(), m.Options.Dialect)
+	})
+	if err != nil {
+		m.t.Fatalf("cannot truncate tables: %v", err)
+	}
+}
+
+// ResetSequences sets every sequence owned by a column, such as the sequence of a serial or identity column,
+// so that its next value follows the maximum value of the column, or is its start value if the table is empty.
+// If UseSchema is set, only the sequences of the temporary schema are reset.
+//
+// Use it after seeding rows with explicit IDs, so that the next insert doesn't collide with them.
+// It is safe to call it multiple times.
+// It is not supported with the Cockroach dialect.
+func (m *Migration) ResetSequences(ctx context.Context) {
+	if m.t == nil {
+		panic("migration must be initialized with sqltest.New()")
+	}
+	m.t.Helper()
+	if m.Options.Dialect == Cockroach {
+		m.t.Fatal("cannot reset sequences: not supported with CockroachDB")
+	}
+	if m.pool == nil {
+		m.t.Fatal("cannot reset sequences: Setup must be called first")
+	}
+	err := m.writeTx(ctx, func(tx pgx.Tx) error {
+		return resetSequences(ctx, tx, m.schema)
+	})
+	if err != nil {
+		m.t.Fatalf("cannot reset sequences: %v", err)
+	}
+}
+
+// resetSequences owned by columns to the maximum value of their column.
+// If schema isn't empty, only the sequences of the schema are reset.
+func resetSequences(ctx context.Context, db interface {
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+}, schema string) error {
+	// Serial columns own their sequence with an automatic dependency, and identity columns with an internal one.
+	rows, err := db.Query(ctx, `SELECT s.oid::regclass::text,
+			quote_ident(tn.nspname) || '.' || quote_ident(t.relname),
+			quote_ident(a.attname),
+			ps.seqstart
+		FROM pg_class s
+		JOIN pg_namespace sn ON sn.oid = s.relnamespace
+		JOIN pg_sequence ps ON ps.seqrelid = s.oid
+		JOIN pg_depend d ON d.objid = s.oid AND d.classid = 'pg_class'::regclass
+			AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+		JOIN pg_class t ON t.oid = d.refobjid
+		JOIN pg_namespace tn ON tn.oid = t.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		WHERE s.relkind = 'S'
+		AND sn.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND ($1 = '' OR sn.nspname = $1)`, schema)
+	if err != nil {
+		return fmt.Errorf("cannot list sequences: %w", err)
+	}
+	defer rows.Close()
+	type sequence struct {
+		name, table, column string
+		start               int64
+	}
+	var sequences []sequence
+	for rows.Next() {
+		var s sequence
+		if err := rows.Scan(&s.name, &s.table, &s.column, &s.start); err != nil {
+			return err
+		}
+		sequences = append(sequences, s)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot list sequences: %w", err)
+	}
+	for _, s := range sequences {
+		// With is_called set to false, the next value is the one set.
+		sql := fmt.Sprintf("SELECT setval($1::regclass, COALESCE((SELECT max(%s) FROM %s) + 1, $2), false)", s.column, s.table)
+		if _, err := db.Exec(ctx, sql, s.name, s.start); err != nil {
+			return fmt.Errorf("cannot reset sequence %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// truncateTables of the database, except for the version table and its checksums,
+// restarting their sequences if the dialect supports it.
+// If schema isn't empty, only the tables of the schema are truncated.
+func truncateTables(ctx context.Context, db interface {
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+}, schema, versionTable string, dialect Dialect) error {
+	rows, err := db.Query(ctx, `SELECT quote_ident(table_schema) || '.' || quote_ident(table_name)
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+		AND table_schema NOT IN ('pg_catalog', 'information_schema')
+		AND ($1 = '' OR table_schema = $1)
+		AND table_name NOT IN ($2, $3) AND table_schema || '.' || table_name NOT IN ($2, $3)`,
+		schema, versionTable, checksumTable(versionTable))
+	if err != nil {
+		return fmt.Errorf("cannot list tables: %w", err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot list tables: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	// CASCADE truncates tables with foreign keys to the truncated ones, so order doesn't matter.
+	_, err = db.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" "+dialect.truncateOptions())
+	return err
+}
+
+// beginTx begins a transaction, and registers its rollback with testing cleanup.
+func beginTx(ctx context.Context, t testing.TB, db interface {
+	Begin(context.Context) (pgx.Tx, error)
+}) pgx.Tx {
+	t.Helper()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			t.Errorf("cannot rollback transaction: %v", err)
+		}
+	})
+	return tx
+}
+
+// checkDatabasePrefix enforces database name to start with "test" to mitigate risk of modifying wrong database by mistake.
+func checkDatabasePrefix(database string) error {
+	if !strings.HasPrefix(database, DatabasePrefix) {
+		return fmt.Errorf(`refusing to run integration tests: database name is %q (%q prefix is required)`, database, DatabasePrefix)
+	}
+	return nil
+}
+
+// seed the database with the SQL file.
+func seed(ctx context.Context, poolConn *pgxpool.Conn, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = poolConn.Exec(ctx, string(b))
+	return err
+}
+
 // migrate database using tern.
 func (m *Migration) migrate(ctx context.Context, poolConn *pgxpool.Conn) (err error) {
-	m.migrator, err = migrate.NewMigrator(ctx, poolConn.Conn(), SchemaVersionTable)
-	if err != nil {
-		return fmt.Errorf("cannot run migration: %w", err)
+	if !m.premigrated {
+		if err := m.beforeMigrate(ctx, poolConn.Conn()); err != nil {
+			return err
+		}
+	}
+	if m.Options.Runner != nil {
+		// A database created from the template database or reused from the pool is already migrated.
+		if m.premigrated {
+			return nil
+		}
+		return m.runMigrations(ctx, poolConn.Conn())
 	}
 
-	m.migrator.OnStart = func(sequence int32, name, direction, sql string) {
-		m.t.Logf("executing %s %s\n", name, direction)
+	m.migrator, err = m.newMigrator(ctx, poolConn.Conn())
+	if err != nil {
+		return fmt.Errorf("cannot run migration: %w", err)
 	}
 
 	// Test the migration scripts and prepare database for integration tests.
-	if err := m.migrator.LoadMigrations(m.Options.Path); err != nil {
+	if err := m.loadMigrations(m.migrator); err != nil {
 		return fmt.Errorf("cannot load migrations: %w", err)
 	}
 
+	// A database created from the template database or reused from the pool is already migrated.
+	if m.premigrated {
+		return nil
+	}
+
+	// Migrations applied to an existing database must not change afterwards.
+	if m.Options.UseExisting || m.persist() {
+		if err := verifyChecksums(ctx, poolConn.Conn(), m.migrator, m.versionTable()); err != nil {
+			return fmt.Errorf("cannot verify migrations: %w", err)
+		}
+	}
+
+	// A persisted database is migrated from the version it was left at.
+	if m.persisted {
+		if err := m.applyMigrations(ctx, poolConn.Conn(), m.migrator, false); err != nil {
+			return err
+		}
+		if err := saveChecksums(ctx, poolConn.Conn(), m.migrator, m.versionTable()); err != nil {
+			return fmt.Errorf("cannot save migration checksums: %w", err)
+		}
+		return nil
+	}
+
 	// Check if the database seems to be in a reliable state.
 	if !m.Options.Force {
 		switch version, err := m.migrator.GetCurrentVersion(ctx); {
 		case err != nil:
 			return fmt.Errorf("cannot get schema version: %w", err)
 		case version != 0:
-			return fmt.Errorf("database is dirty, please fix %q table manually or try -force", SchemaVersionTable)
+			return fmt.Errorf("database is dirty, please fix %q table manually or try -force", m.versionTable())
 		}
 	}
 
-	// Undo database migrations.
-	if err := m.migrator.MigrateTo(ctx, 0); err != nil {
-		return fmt.Errorf("cannot undo database migrations: %v", err)
+	// Undo database migrations, and migrate to latest version of the database.
+	if err := m.applyMigrations(ctx, poolConn.Conn(), m.migrator, true); err != nil {
+		return err
 	}
+	if m.Options.UseExisting || m.persist() {
+		if err := saveChecksums(ctx, poolConn.Conn(), m.migrator, m.versionTable()); err != nil {
+			return fmt.Errorf("cannot save migration checksums: %w", err)
+		}
+	}
+	return nil
+}
 
-	// Migrate to latest version of the database
-	if err := m.migrator.Migrate(ctx); err != nil {
-		return fmt.Errorf("cannot apply migrations: %v", err)
+// beforeMigrate calls the BeforeMigrate hook, if set.
+func (m *Migration) beforeMigrate(ctx context.Context, conn *pgx.Conn) error {
+	if m.Options.BeforeMigrate == nil {
+		return nil
+	}
+	if err := m.Options.BeforeMigrate(ctx, conn); err != nil {
+		return fmt.Errorf("BeforeMigrate failed: %w", err)
 	}
 	return nil
 }
@@ -194,42 +1073,223 @@ func (m *Migration) migrate(ctx context.Context, poolConn *pgxpool.Conn) (err er
 // In case this is not called, you can use the Force option to reset the database.
 func (m *Migration) Teardown(ctx context.Context) {
 	m.t.Helper()
-	m.t.Log("teardown PostgreSQL database")
-	if err := m.migrator.MigrateTo(ctx, 0); err != nil {
-		m.t.Fatalf("cannot tear down database migrations: %v", err)
+	m.logf("teardown PostgreSQL database")
+	defer m.releaseName()
+	if m.persist() {
+		m.pool.Close()
+		defer m.closeAdmin(ctx)
+		m.logf("keeping database %q", m.database)
+		return
+	}
+	if m.keep() {
+		m.pool.Close()
+		defer m.closeAdmin(ctx)
+		switch {
+		case m.Options.UseSchema:
+			m.logf("keeping schema %q in database %q", m.schema, m.database)
+		case !m.Options.UseExisting:
+			m.logf("keeping database %q", m.database)
+		}
+		// Don't return the database to the pool, so it isn't reused or replaced.
+		m.databasePool = nil
+		return
+	}
+	if m.databasePool != nil {
+		defer m.closeAdmin(ctx)
+		err := m.releaseDatabase(ctx)
+		m.databasePool = nil
+		m.pool.Close()
+		if err != nil {
+			m.t.Fatalf("cannot release database to the pool: %v", err)
+		}
+		return
+	}
+	if m.downMigrations {
+		// The migrations are undone on the connection used to apply them.
+		if m.Options.ReadOnly && m.setupConn != nil {
+			if _, err := m.setupConn.Exec(ctx, "SET default_transaction_read_only = off"); err != nil {
+				m.t.Fatalf("cannot tear down database migrations: %v", err)
+			}
+		}
+		if err := m.migrator.MigrateTo(ctx, 0); err != nil {
+			m.logFailedStatement(ctx, nil, err)
+			m.t.Fatalf("cannot tear down database migrations: %v", err)
+		}
 	}
 	m.pool.Close()
 
-	if !m.Options.UseExisting {
-		defer m.conn.Close(ctx)
+	switch {
+	case m.Options.UseSchema:
+		defer m.closeAdmin(ctx)
+		if err := m.dropSchema(ctx); err != nil {
+			m.t.Fatalf("cannot drop schema: %v", err)
+		}
+	case !m.Options.UseExisting:
+		defer m.closeAdmin(ctx)
 		if err := m.dropDB(ctx); err != nil {
 			m.t.Fatalf("cannot drop database: %v", err)
 		}
 	}
 }
 
+// keep reports whether the database should be kept after the tests for debugging.
+func (m *Migration) keep() bool {
+	return os.Getenv(keepEnv) != "" || (m.Options.KeepOnFailure && m.t.Failed())
+}
+
+// persist reports whether the temporary database is persisted across test runs.
+func (m *Migration) persist() bool {
+	return m.Options.Persist && !m.Options.UseExisting && !m.Options.UseSchema
+}
+
+// persistDB creates the temporary database when Persist is used, unless it exists already.
+func (m *Migration) persistDB(ctx context.Context) error {
+	if m.Options.Force || m.Options.ForceReset {
+		if err := m.dropDB(ctx); err != nil {
+			return err
+		}
+	}
+	if err := m.adminQueryRow(ctx, &m.persisted, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1);", m.database); err != nil {
+		return err
+	}
+	if m.persisted {
+		m.logf("reusing database %q", m.database)
+		return nil
+	}
+	return m.adminExec(ctx, fmt.Sprintf(`CREATE DATABASE "%s"%s%s;`, m.database, m.Options.databaseSettings(), m.Options.ownerClause("OWNER")))
+}
+
 // cleanDB creates a temporary database when CleanDB is used.
 func (m *Migration) cleanDB(ctx context.Context, connString string) error {
 	// If force is set to true, drop database if it exists.
-	if m.Options.Force {
+	if m.Options.Force || m.Options.ForceReset {
 		if err := m.dropDB(ctx); err != nil {
 			return err
 		}
 	}
 
 	// Create new database.
-	_, err := m.conn.Exec(ctx, fmt.Sprintf(`CREATE DATABASE "%s";`, m.database))
+	var err error
+	if m.template != "" {
+		err = m.adminExec(ctx, fmt.Sprintf(`CREATE DATABASE "%s" TEMPLATE "%s"%s;`, m.database, m.template, m.Options.ownerClause("OWNER")))
+	} else {
+		err = m.adminExec(ctx, fmt.Sprintf(`CREATE DATABASE "%s"%s%s;`, m.database, m.Options.databaseSettings(), m.Options.ownerClause("OWNER")))
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P04" { // duplicate_database
+		return fmt.Errorf("database %q already exists, and wasn't created by this test: "+
+			"it might be used by a test in another process with a name shortened to the same %d bytes identifier, "+
+			"or left behind by a previous test run (use the ForceReset option to replace it): %w", m.database, maxIdentifierLength, err)
+	}
 	return err
 }
 
 // dropDB drops the created temporary database.
 func (m *Migration) dropDB(ctx context.Context) error {
-	_, err := m.conn.Exec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, m.database))
+	return m.adminExec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, m.database))
+}
+
+// createSchema creates a temporary schema when UseSchema is used.
+func (m *Migration) createSchema(ctx context.Context) error {
+	// If force is set to true, drop schema if it exists.
+	if m.Options.Force || m.Options.ForceReset {
+		if err := m.dropSchema(ctx); err != nil {
+			return err
+		}
+	}
+	err := m.adminExec(ctx, fmt.Sprintf(`CREATE SCHEMA "%s"%s;`, m.schema, m.Options.ownerClause("AUTHORIZATION")))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P06" { // duplicate_schema
+		return fmt.Errorf("schema %q already exists, and wasn't created by this test: "+
+			"it might be used by a test in another process with a name shortened to the same %d bytes identifier, "+
+			"or left behind by a previous test run (use the ForceReset option to replace it): %w", m.schema, maxIdentifierLength, err)
+	}
 	return err
 }
 
+// dropSchema drops the created temporary schema, and everything in it.
+func (m *Migration) dropSchema(ctx context.Context) error {
+	return m.adminExec(ctx, fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE;`, m.schema))
+}
+
+// versionTable returns the table where tern saves the version of the current migration.
+func (m *Migration) versionTable() string {
+	if m.Options.VersionTable != "" {
+		return m.Options.VersionTable
+	}
+	return SchemaVersionTable
+}
+
+// temporaryName returns the name of the temporary database or schema of the test.
+func (m *Migration) temporaryName() string {
+	nameFunc := m.Options.NameFunc
+	if nameFunc == nil {
+		nameFunc = SQLTestName
+	}
+	return shortenIdentifier(m.Options.TemporaryDatabasePrefix + nameFunc(m.t))
+}
+
+// temporaryNames of the databases and schemas in use by the tests of this process,
+// so that tests whose names are shortened to the same identifier don't interfere with each other.
+var temporaryNames = struct {
+	mu sync.Mutex // guards following
+	m  map[string]*Migration
+}{
+	m: map[string]*Migration{},
+}
+
+// reserveName of the temporary database or schema for the test until Teardown,
+// failing if it's in use by another test.
+func (m *Migration) reserveName(name string) error {
+	temporaryNames.mu.Lock()
+	defer temporaryNames.mu.Unlock()
+	if other, ok := temporaryNames.m[name]; ok && other != m {
+		return fmt.Errorf("cannot use %q for %s, as it's already used by %s: "+
+			"names longer than PostgreSQL's limit of %d bytes are shortened, and might collide; "+
+			"use Options.NameFunc to choose distinct names", name, m.t.Name(), other.t.Name(), maxIdentifierLength)
+	}
+	temporaryNames.m[name] = m
+	m.t.Cleanup(m.releaseName)
+	return nil
+}
+
+// releaseName of the temporary database or schema reserved by the test.
+func (m *Migration) releaseName() {
+	temporaryNames.mu.Lock()
+	defer temporaryNames.mu.Unlock()
+	for name, other := range temporaryNames.m {
+		if other == m {
+			delete(temporaryNames.m, name)
+		}
+	}
+}
+
+// maxIdentifierLength is the maximum length of an identifier in PostgreSQL, in bytes.
+// Longer identifiers are truncated by PostgreSQL.
+const maxIdentifierLength = 63
+
 // SQLTestName normalizes a test name to a database name.
 // It lowercases the test name and converts / to underscore.
+//
+// Names longer than PostgreSQL's limit of 63 bytes are shortened, and suffixed with a hash
+// of the full name, so the names of subtests sharing a long prefix don't collide.
+// Setup shortens the name again if it's too long once prefixed with TemporaryDatabasePrefix.
 func SQLTestName(t testing.TB) string {
-	return strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_"))
+	return shortenIdentifier(strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_")))
+}
+
+// shortenIdentifier to fit PostgreSQL's identifier length limit, replacing the end of
+// a long identifier with a hash of the whole identifier.
+func shortenIdentifier(name string) string {
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+	h := sha256.Sum256([]byte(name))
+	suffix := "_" + hex.EncodeToString(h[:])[:12]
+	n := maxIdentifierLength - len(suffix)
+	// Don't cut a multi-byte character in half.
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+	return name[:n] + suffix
 }
@@ -6,16 +6,22 @@ package sqltest_test
 import (
 	"bytes"
 	"context"
+	"embed"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/partounian/pgtools/sqltest"
 )
@@ -59,122 +65,1574 @@ func TestPrefixedDatabase(t *testing.T) {
 	if want := "test_must_have_prefix_testprefixeddatabase"; want != got {
 		t.Errorf("got %q, wanted %q", got, want)
 	}
+	if name := migration.DatabaseName(); name != got {
+		t.Errorf("got database name %q, wanted %q", name, got)
+	}
+}
+
+func TestAppliedVersions(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		path string
+		want []int
+	}{
+		{
+			desc: "tern",
+			path: "example/testdata/migrations",
+			want: []int{1, 2, 3},
+		},
+		{
+			desc: "updown",
+			path: "example/testdata/updown-migrations",
+			want: []int{1, 2},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			migration := sqltest.New(t, sqltest.Options{
+				Force:                   *force,
+				Path:                    tc.path,
+				TemporaryDatabasePrefix: "test_internal_",
+			})
+			migration.Setup(context.Background(), "")
+			if got := migration.AppliedVersions(); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("got applied versions %v, wanted %v", got, tc.want)
+			}
+		})
+	}
+}
+
+//go:embed example/testdata/migrations/*.sql
+var embeddedMigrations embed.FS
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		FS:                      embeddedMigrations,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	conn := migration.Setup(ctx, "")
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d posts, wanted none", n)
+	}
+}
+
+// upRunner applies the *.up.sql files in order, without tracking versions.
+type upRunner struct{}
+
+func (upRunner) Run(ctx context.Context, conn *pgx.Conn, source fs.FS) error {
+	files, err := fs.Glob(source, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		b, err := fs.ReadFile(source, f)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, string(b)); err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+	}
+	return nil
+}
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/updown-migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Runner:                  upRunner{},
+	})
+	conn := migration.Setup(ctx, "")
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_tables WHERE tablename = $1)", sqltest.SchemaVersionTable).Scan(&exists); err != nil {
+		t.Errorf("cannot check version table: %v", err)
+	}
+	if exists {
+		t.Errorf("expected %q table not to exist", sqltest.SchemaVersionTable)
+	}
+}
+
+func TestVersionTable(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc         string
+		versionTable string
+		schema       string
+		table        string
+	}{
+		{
+			desc:         "table",
+			versionTable: "migrations_version",
+			schema:       "public",
+			table:        "migrations_version",
+		},
+		{
+			desc:         "schema",
+			versionTable: "public.qualified_version",
+			schema:       "public",
+			table:        "qualified_version",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			migration := sqltest.New(t, sqltest.Options{
+				Force:                   *force,
+				Path:                    "example/testdata/migrations",
+				TemporaryDatabasePrefix: "test_internal_",
+				VersionTable:            tc.versionTable,
+			})
+			conn := migration.Setup(ctx, "")
+			for table, want := range map[string]bool{
+				tc.table:                   true,
+				sqltest.SchemaVersionTable: false,
+			} {
+				var exists bool
+				if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_tables WHERE schemaname = $1 AND tablename = $2)", tc.schema, table).Scan(&exists); err != nil {
+					t.Fatalf("cannot check version table: %v", err)
+				}
+				if exists != want {
+					t.Errorf("expected %q table to exist to be %v, got %v instead", table, want, exists)
+				}
+			}
+			if got, want := migration.AppliedVersions(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected applied versions to be %v, got %v instead", want, got)
+			}
+			migration.Truncate(ctx)
+			if got, want := migration.AppliedVersions(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected applied versions after Truncate to be %v, got %v instead", want, got)
+			}
+		})
+	}
+}
+
+func TestBeforeMigrate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var calls int
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "testdata/before-migrate",
+		TemporaryDatabasePrefix: "test_internal_",
+		BeforeMigrate: func(ctx context.Context, conn *pgx.Conn) error {
+			calls++
+			_, err := conn.Exec(ctx, "CREATE SCHEMA app")
+			return err
+		},
+	})
+	conn := migration.Setup(ctx, "")
+	if calls != 1 {
+		t.Errorf("got BeforeMigrate called %d times, wanted once", calls)
+	}
+	if _, err := conn.Exec(ctx, "INSERT INTO app.items (id) VALUES ('1')"); err != nil {
+		t.Errorf("cannot insert item: %v", err)
+	}
+}
+
+var checkBeforeMigrateError = flag.Bool("check_before_migrate_error", false, "if true, TestBeforeMigrateError should fail.")
+
+func TestBeforeMigrateError(t *testing.T) {
+	t.Parallel()
+	if *checkBeforeMigrateError {
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   *force,
+			Path:                    "testdata/before-migrate",
+			TemporaryDatabasePrefix: "test_internal_",
+			BeforeMigrate: func(ctx context.Context, conn *pgx.Conn) error {
+				return errors.New("no roles")
+			},
+		})
+		migration.Setup(context.Background(), "")
+		return
+	}
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestBeforeMigrateError", "-check_before_migrate_error").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	if want := "BeforeMigrate failed: no roles"; !bytes.Contains(out, []byte(want)) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+}
+
+func TestAdminConn(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	admin, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot connect to database: %v", err)
+	}
+	defer admin.Close(ctx)
+
+	var databases []string
+	var mu sync.Mutex
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"first", "second"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				migration := sqltest.New(t, sqltest.Options{
+					Force:                   *force,
+					Path:                    "example/testdata/migrations",
+					TemporaryDatabasePrefix: "test_internal_",
+					AdminConn:               admin,
+				})
+				migration.Setup(ctx, "")
+				mu.Lock()
+				databases = append(databases, migration.DatabaseName())
+				mu.Unlock()
+			})
+		}
+	})
+
+	// The connection must still be open, and used to drop the databases.
+	for _, database := range databases {
+		var exists bool
+		if err := admin.QueryRow(ctx, "SELECT EXISTS (SELECT FROM pg_database WHERE datname = $1)", database).Scan(&exists); err != nil {
+			t.Fatalf("cannot check database: %v", err)
+		}
+		if exists {
+			t.Errorf("expected database %q to be dropped", database)
+		}
+	}
+}
+
+func TestAfterMigrate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var calls int
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		AfterMigrate: func(ctx context.Context, conn *pgx.Conn) error {
+			calls++
+			_, err := conn.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('1', 'hello', 'Hello, world!')")
+			return err
+		},
+	})
+	conn := migration.Setup(ctx, "")
+	if calls != 1 {
+		t.Errorf("got AfterMigrate called %d times, wanted once", calls)
+	}
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d posts, wanted %d", n, 1)
+	}
+}
+
+func TestAfterConnect(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		AfterConnect: func(ctx context.Context, conn *pgx.Conn) error {
+			// The media_type enum is created by the migrations.
+			_, err := conn.Exec(ctx, "SET application_name = 'sqltest'; SELECT 'photo'::media_type")
+			return err
+		},
+	})
+	pool := migration.Setup(ctx, "")
+
+	// Acquire more than one connection to check AfterConnect is called on new connections too.
+	for i := 0; i < 2; i++ {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("cannot acquire connection: %v", err)
+		}
+		defer conn.Release()
+		var name string
+		if err := conn.QueryRow(ctx, "SHOW application_name").Scan(&name); err != nil {
+			t.Errorf("cannot get application name: %v", err)
+		}
+		if want := "sqltest"; name != want {
+			t.Errorf("got application name %q, wanted %q", name, want)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Seed:                    []string{"example/testdata/seed/posts.sql"},
+	})
+	conn := migration.Setup(ctx, "")
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d posts, wanted %d", n, 2)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Seed:                    []string{"example/testdata/seed/posts.sql"},
+	})
+	conn := migration.Setup(ctx, "")
+	migration.Truncate(ctx)
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d posts after truncating, wanted none", n)
+	}
+	var version int
+	if err := conn.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&version); err != nil {
+		t.Errorf("cannot get schema version: %v", err)
+	}
+	if want := 3; version != want {
+		t.Errorf("got schema version %d, wanted %d", version, want)
+	}
+}
+
+func TestResetSequences(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "testdata/sequences",
+		TemporaryDatabasePrefix: "test_internal_",
+		Seed:                    []string{"testdata/seed/counters.sql"},
+	})
+	conn := migration.Setup(ctx, "")
+	// Calling it more than once must not skip values.
+	migration.ResetSequences(ctx)
+	migration.ResetSequences(ctx)
+	for table, want := range map[string]int{
+		"serials":    8,
+		"identities": 100, // Empty, so the sequence starts over.
+	} {
+		var id int
+		if err := conn.QueryRow(ctx, "INSERT INTO "+table+" (name) VALUES ('new') RETURNING id").Scan(&id); err != nil {
+			t.Errorf("cannot insert into %s: %v", table, err)
+		}
+		if id != want {
+			t.Errorf("expected id of %s to be %d, got %d instead", table, want, id)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/updown-migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		RunDownMigrations:       true,
+		Seed:                    []string{"example/testdata/seed/posts.sql"},
+		ReadOnly:                true,
+	})
+	conn := migration.Setup(ctx, "")
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n == 0 {
+		t.Error("expected seeded posts")
+	}
+
+	var pgErr *pgconn.PgError
+	_, err := conn.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('ro', 'hello', 'Hello, world!')")
+	if !errors.As(err, &pgErr) || pgErr.Code != "25006" { // read_only_sql_transaction
+		t.Errorf("expected read-only error inserting with the pool, got %v instead", err)
+	}
+	tx := migration.SetupTx(ctx)
+	_, err = tx.Exec(ctx, "DELETE FROM posts")
+	if !errors.As(err, &pgErr) || pgErr.Code != "25006" {
+		t.Errorf("expected read-only error deleting in a transaction, got %v instead", err)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Errorf("cannot rollback transaction: %v", err)
+	}
+
+	migration.Truncate(ctx)
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d posts after truncating, wanted none", n)
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+	for _, database := range []string{"test_orphan_a", "test_orphan_b", "test_orphanage"} {
+		if _, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+database); err != nil {
+			t.Fatalf("cannot drop database: %v", err)
+		}
+		if _, err := conn.Exec(ctx, "CREATE DATABASE "+database); err != nil {
+			t.Fatalf("cannot create database: %v", err)
+		}
+	}
+	defer conn.Exec(ctx, "DROP DATABASE IF EXISTS test_orphanage")
+
+	dropped, err := sqltest.CleanupOrphans(ctx, "test_orphan_")
+	if err != nil {
+		t.Fatalf("cannot clean up orphans: %v", err)
+	}
+	if want := []string{"test_orphan_a", "test_orphan_b"}; !reflect.DeepEqual(want, dropped) {
+		t.Errorf("expected dropped databases to be %q, got %q instead", want, dropped)
+	}
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT FROM pg_database WHERE datname = 'test_orphanage')").Scan(&exists); err != nil {
+		t.Fatalf("cannot check database: %v", err)
+	}
+	if !exists {
+		t.Error("expected database not matching the prefix to be kept")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	migration.Setup(ctx, "")
+	listener := migration.Connect(ctx)
+	notifier := migration.Connect(ctx)
+
+	var database string
+	if err := listener.QueryRow(ctx, "SELECT current_database()").Scan(&database); err != nil {
+		t.Fatalf("cannot get database name: %v", err)
+	}
+	if want := migration.DatabaseName(); database != want {
+		t.Errorf("expected database to be %q, got %q instead", want, database)
+	}
+
+	if _, err := listener.Exec(ctx, "LISTEN events"); err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	if _, err := notifier.Exec(ctx, "SELECT pg_notify('events', 'created')"); err != nil {
+		t.Fatalf("cannot notify: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err := listener.WaitForNotification(waitCtx)
+	if err != nil {
+		t.Fatalf("cannot wait for notification: %v", err)
+	}
+	if want := "created"; n.Payload != want {
+		t.Errorf("expected payload to be %q, got %q instead", want, n.Payload)
+	}
+}
+
+func TestWaitForNotification(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	pool := migration.Setup(ctx, "")
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	type result struct {
+		n   *pgconn.Notification
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := migration.WaitForNotification(waitCtx, "user events")
+		done <- result{n, err}
+	}()
+
+	// Notify until received, as the channel might not be listened to yet.
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := pool.Exec(ctx, "SELECT pg_notify('user events', 'created')"); err != nil {
+			t.Fatalf("cannot notify: %v", err)
+		}
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatalf("cannot wait for notification: %v", r.err)
+			}
+			if want := "created"; r.n.Payload != want {
+				t.Errorf("expected payload to be %q, got %q instead", want, r.n.Payload)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func TestWaitForNotificationTimeout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	migration.Setup(ctx, "")
+
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := migration.WaitForNotification(waitCtx, "events"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v instead", err)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS test_internal_persist"); err != nil {
+		t.Fatalf("cannot drop database: %v", err)
+	}
+	defer conn.Exec(ctx, "DROP DATABASE IF EXISTS test_internal_persist")
+
+	opts := sqltest.Options{
+		Path:                    "testdata/idempotent",
+		TemporaryDatabasePrefix: "test_internal_",
+		NameFunc: func(t testing.TB) string {
+			return "persist"
+		},
+		Persist: true,
+	}
+	t.Run("create", func(t *testing.T) {
+		pool := sqltest.New(t, opts).Setup(ctx, "")
+		if _, err := pool.Exec(ctx, "INSERT INTO tags (id, name) VALUES ('go', 'Go')"); err != nil {
+			t.Fatalf("cannot insert tag: %v", err)
+		}
+	})
+	t.Run("reuse", func(t *testing.T) {
+		pool := sqltest.New(t, opts).Setup(ctx, "")
+		var name string
+		if err := pool.QueryRow(ctx, "SELECT name FROM tags WHERE id = 'go'").Scan(&name); err != nil {
+			t.Fatalf("cannot get tag from persisted database: %v", err)
+		}
+	})
+	t.Run("after connect", func(t *testing.T) {
+		reused := opts
+		var connected int32
+		reused.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			atomic.AddInt32(&connected, 1)
+			return nil
+		}
+		reused.ReadOnly = true
+		pool := sqltest.New(t, reused).Setup(ctx, "")
+		if atomic.LoadInt32(&connected) == 0 {
+			t.Error("expected AfterConnect to be called for a persisted database")
+		}
+		_, err := pool.Exec(ctx, "INSERT INTO tags (id, name) VALUES ('sql', 'SQL')")
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "25006" { // read_only_sql_transaction
+			t.Errorf("expected read-only error, got %v instead", err)
+		}
+	})
+	t.Run("force", func(t *testing.T) {
+		forced := opts
+		forced.Force = true
+		pool := sqltest.New(t, forced).Setup(ctx, "")
+		var n int
+		if err := pool.QueryRow(ctx, "SELECT count(*) FROM tags").Scan(&n); err != nil {
+			t.Fatalf("cannot count tags: %v", err)
+		}
+		if n != 0 {
+			t.Errorf("expected database to be recreated with Force, got %d tags instead", n)
+		}
+	})
+}
+
+var checkVerifyIdempotent = flag.Bool("check_verify_idempotent", false, "if true, TestVerifyIdempotent should fail.")
+
+func TestVerifyIdempotent(t *testing.T) {
+	t.Parallel()
+	if *checkVerifyIdempotent {
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   *force,
+			Path:                    "testdata/not-idempotent",
+			TemporaryDatabasePrefix: "test_internal_",
+			VerifyIdempotent:        true,
+		})
+		migration.Setup(context.Background(), "")
+		return
+	}
+
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "testdata/idempotent",
+		TemporaryDatabasePrefix: "test_internal_",
+		VerifyIdempotent:        true,
+	})
+	migration.Setup(context.Background(), "")
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestVerifyIdempotent", "-check_verify_idempotent").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	for _, want := range []string{
+		"migration failed at line 6, statement 2 of 2:",
+		"migration 001_tags.sql isn't idempotent",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("got %q, wanted %q", out, want)
+		}
+	}
+}
+
+func TestUseTemplate(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			migration := sqltest.New(t, sqltest.Options{
+				Force:                   *force,
+				Path:                    "example/testdata/migrations",
+				TemporaryDatabasePrefix: "test_internal_",
+				UseTemplate:             true,
+			})
+			conn := migration.Setup(ctx, "")
+			var version int
+			if err := conn.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&version); err != nil {
+				t.Errorf("cannot get schema version: %v", err)
+			}
+			if version != 3 {
+				t.Errorf("got schema version %d, wanted %d", version, 3)
+			}
+			if _, err := conn.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('1', 'hello', 'Hello, world!')"); err != nil {
+				t.Errorf("cannot insert post: %v", err)
+			}
+		})
+	}
+}
+
+var templateProcess = flag.String("template_process", "", "if set, TestUseTemplateProcesses sets up a database from a template as the named process.")
+
+func TestUseTemplateProcesses(t *testing.T) {
+	t.Parallel()
+	if *templateProcess != "" {
+		migration := sqltest.New(t, sqltest.Options{
+			Path:                    "testdata/template-lock",
+			TemporaryDatabasePrefix: "test_process_" + *templateProcess + "_",
+			UseTemplate:             true,
+		})
+		conn := migration.Setup(context.Background(), "")
+		if _, err := conn.Exec(context.Background(), "INSERT INTO events (id, name) VALUES (1, 'started')"); err != nil {
+			t.Errorf("cannot insert event: %v", err)
+		}
+		return
+	}
+
+	// Processes starting at the same time must not create the template database twice.
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := exec.Command(os.Args[0], "-test.run=TestUseTemplateProcesses", "-template_process="+name).CombinedOutput()
+			if err != nil {
+				t.Errorf("process %s failed: %v\n%s", name, err, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+var templateRun = flag.Bool("template_run", false, "if true, TestUseTemplateRuns sets up a database from a template.")
+
+func TestUseTemplateRuns(t *testing.T) {
+	t.Parallel()
+	if *templateRun {
+		migration := sqltest.New(t, sqltest.Options{
+			Path:                    "testdata/template-lock",
+			TemporaryDatabasePrefix: "test_run_",
+			VersionTable:            "template_runs_version",
+			UseTemplate:             true,
+		})
+		migration.Setup(context.Background(), "")
+		return
+	}
+
+	// Later runs of the tests must reuse the template database instead of migrating it again.
+	for i := 0; i < 2; i++ {
+		out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestUseTemplateRuns", "-template_run").CombinedOutput()
+		if err != nil {
+			t.Fatalf("run %d failed: %v\n%s", i, err, out)
+		}
+		if i == 1 && !bytes.Contains(out, []byte("using existing template database")) {
+			t.Errorf("expected second run to reuse the template database, got %q instead", out)
+		}
+	}
+}
+
+func TestOwner(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+	// Roles are shared by the databases of the server, so it might exist already.
+	_, err = conn.Exec(ctx, `DO $$ BEGIN
+		CREATE ROLE test_owner;
+	EXCEPTION WHEN duplicate_object THEN NULL;
+	END $$`)
+	if err != nil {
+		t.Fatalf("cannot create role: %v", err)
+	}
+
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Owner:                   "test_owner",
+	})
+	pool := migration.Setup(ctx, "")
+	var owner string
+	if err := pool.QueryRow(ctx, "SELECT pg_get_userbyid(datdba) FROM pg_database WHERE datname = current_database()").Scan(&owner); err != nil {
+		t.Fatalf("cannot get database owner: %v", err)
+	}
+	if want := "test_owner"; owner != want {
+		t.Errorf("expected database owner to be %q, got %q instead", want, owner)
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		path string
+		data interface{}
+	}{
+		{
+			desc: "tern",
+			path: "testdata/template-data/tern",
+			data: map[string]interface{}{"Table": "gadgets"},
+		},
+		{
+			desc: "updown",
+			path: "testdata/template-data/updown",
+			data: struct{ Table string }{"gadgets"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			migration := sqltest.New(t, sqltest.Options{
+				Force:                   *force,
+				Path:                    tc.path,
+				TemporaryDatabasePrefix: "test_internal_",
+				TemplateData:            tc.data,
+				RunDownMigrations:       true,
+			})
+			pool := migration.Setup(ctx, "")
+			if _, err := pool.Exec(ctx, "INSERT INTO gadgets (id, name) VALUES (1, 'lever')"); err != nil {
+				t.Errorf("cannot insert into table named by the template data: %v", err)
+			}
+		})
+	}
+}
+
+func TestDatabaseSettings(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Template:                "template0",
+		Encoding:                "UTF8",
+		Locale:                  "C",
+	})
+	pool := migration.Setup(ctx, "")
+	var encoding, collate string
+	err := pool.QueryRow(ctx, "SELECT pg_encoding_to_char(encoding), datcollate FROM pg_database WHERE datname = current_database()").Scan(&encoding, &collate)
+	if err != nil {
+		t.Fatalf("cannot get database settings: %v", err)
+	}
+	if want := "UTF8"; encoding != want {
+		t.Errorf("expected encoding to be %q, got %q instead", want, encoding)
+	}
+	if want := "C"; collate != want {
+		t.Errorf("expected collation to be %q, got %q instead", want, collate)
+	}
+	// The C collation sorts upper case letters before lower case ones.
+	var words []string
+	if err := pool.QueryRow(ctx, "SELECT array_agg(w ORDER BY w) FROM unnest(ARRAY['b', 'a', 'B']) w").Scan(&words); err != nil {
+		t.Fatalf("cannot sort words: %v", err)
+	}
+	if want := []string{"B", "a", "b"}; !reflect.DeepEqual(want, words) {
+		t.Errorf("expected words to be sorted as %q, got %q instead", want, words)
+	}
+}
+
+func TestUseSchema(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_schema_",
+		UseSchema:               true,
+	})
+	conn := migration.Setup(ctx, "")
+	var schema string
+	if err := conn.QueryRow(ctx, "SELECT current_schema();").Scan(&schema); err != nil {
+		t.Errorf("cannot get schema: %v", err)
+	}
+	if want := "test_schema_testuseschema"; schema != want {
+		t.Errorf("got schema %q, wanted %q", schema, want)
+	}
+	var tables int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM information_schema.tables WHERE table_schema = $1", schema).Scan(&tables); err != nil {
+		t.Errorf("cannot count tables: %v", err)
+	}
+	if want := 4; tables != want { // media, settings, posts, and schema_version.
+		t.Errorf("got %d tables, wanted %d", tables, want)
+	}
+}
+
+func TestSetupTx(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	conn := migration.Setup(ctx, "")
+	countPosts := func(db interface {
+		QueryRow(context.Context, string, ...interface{}) pgx.Row
+	}) int {
+		var n int
+		if err := db.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+			t.Errorf("cannot count posts: %v", err)
+		}
+		return n
+	}
+	// Cleanup functions are called in last added, first called order.
+	t.Cleanup(func() {
+		if n := countPosts(conn); n != 0 {
+			t.Errorf("got %d posts after rollback, wanted none", n)
+		}
+	})
+
+	tx := migration.SetupTx(ctx)
+	if _, err := tx.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('1', 'hello', 'Hello, world!')"); err != nil {
+		t.Errorf("cannot insert post: %v", err)
+	}
+	sp := migration.Savepoint(ctx, tx)
+	if _, err := sp.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('2', 'bye', 'Goodbye, world!')"); err != nil {
+		t.Errorf("cannot insert post: %v", err)
+	}
+	if err := sp.Rollback(ctx); err != nil {
+		t.Errorf("cannot rollback savepoint: %v", err)
+	}
+	if n := countPosts(tx); n != 1 {
+		t.Errorf("got %d posts inside the transaction, wanted %d", n, 1)
+	}
+	if n := countPosts(conn); n != 0 {
+		t.Errorf("got %d posts outside the transaction, wanted none", n)
+	}
+}
+
+func TestShared(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	shared := sqltest.NewShared(sqltest.Options{
+		Force:                   *force,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Logger:                  t,
+	})
+	pool, err := shared.Setup(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot set up shared database: %v", err)
+	}
+	database := shared.DatabaseName()
+	if want := "test_internal_test_shared_sqltest_"; !strings.HasPrefix(database, want) {
+		t.Errorf("expected database name to start with %q, got %q instead", want, database)
+	}
+
+	// Each subtest inserts the same post, which only works if the other's transaction was rolled back.
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			tx := shared.SetupTx(ctx, t)
+			if _, err := tx.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('1', 'hello', 'Hello, world!')"); err != nil {
+				t.Errorf("cannot insert post: %v", err)
+			}
+		})
+	}
+	var n int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d posts after the tests, wanted none", n)
+	}
+
+	if err := shared.Teardown(); err != nil {
+		t.Fatalf("cannot tear down shared database: %v", err)
+	}
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("cannot connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT FROM pg_database WHERE datname = $1)", database).Scan(&exists); err != nil {
+		t.Fatalf("cannot check database: %v", err)
+	}
+	if exists {
+		t.Errorf("expected database %q to be dropped", database)
+	}
+}
+
+func TestSharedDefaultOptions(t *testing.T) {
+	t.Parallel()
+	shared := sqltest.NewShared(sqltest.Options{
+		Force: *force,
+		Path:  "example/testdata/migrations",
+	})
+	if _, err := shared.Setup(context.Background(), ""); err != nil {
+		t.Fatalf("cannot set up shared database: %v", err)
+	}
+	defer func() {
+		if err := shared.Teardown(); err != nil {
+			t.Errorf("cannot tear down shared database: %v", err)
+		}
+	}()
+	if want, got := "test_shared_sqltest_", shared.DatabaseName(); !strings.HasPrefix(got, want) {
+		t.Errorf("expected database name to start with %q, got %q instead", want, got)
+	}
+}
+
+var checkMigrationInvalidPath = flag.Bool("check_migration_invalid_path", false, "if true, TestMigrationInvalidPath should fail.")
+
+func TestMigrationInvalidPath(t *testing.T) {
+	if *checkMigrationInvalidPath {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{Force: *force, Path: "testdata/invalid", UseExisting: true})
+		migration.Setup(ctx, "")
+		return
+	}
+
+	args := []string{
+		"-test.v",
+		"-test.run=TestMigrationInvalidPath",
+		"-check_migration_invalid_path",
+	}
+	if *force {
+		args = append(args, "-force")
+	}
+	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	if want := []byte("cannot load migrations: open testdata/invalid: no such file or directory"); !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+}
+
+// Check what happens if there is a dirty migration.
+var checkMigrationDirty = flag.Bool("check_migration_dirty", false, "if true, TestMigrationDirty should fail.")
+
+func TestMigrationDirty(t *testing.T) {
+	if *checkMigrationDirty {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{Path: "example/testdata/migrations", UseExisting: true})
+		migration.Setup(ctx, "")
+		return
+	}
+
+	// Prepare clean environment.
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{Force: *force, Path: "example/testdata/migrations", UseExisting: true})
+	conn := migration.Setup(ctx, "")
+
+	// Check if the migration version matches with the number of migration files.
+	entries, err := ioutil.ReadDir("example/testdata/migrations")
+	if err != nil {
+		t.Errorf("cannot read migrations dir: %v", err)
+	}
+	var migrations int
+	for _, f := range entries {
+		if strings.HasSuffix(f.Name(), ".sql") {
+			migrations++
+		}
+	}
+
+	// Let's update the schema_version to make it dirty, and verify we are unable to run the tests.
+	if _, err := conn.Exec(context.Background(), "UPDATE schema_version SET version = $1 WHERE version = $2", migrations+1, migrations); err != nil {
+		t.Errorf("cannot update migration version: %q", err)
+	}
+
+	args := []string{
+		"-test.v",
+		"-test.run=TestMigrationDirty",
+		"-check_migration_dirty",
+	}
+	if *force {
+		args = append(args, "-force")
+	}
+	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	want := []byte(`database is dirty, please fix "schema_version" table manually or try -force`)
+	if !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+
+	// Manually reset migration version to be able to test again to after the first 'legit' migration:
+	if _, err := conn.Exec(context.Background(), "UPDATE schema_version SET version = $1", migrations); err != nil {
+		t.Errorf("cannot update migration version: %q", err)
+	}
+}
+
+func TestForceReset(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("connection error: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	// Leave a stale database behind, as if a previous test run crashed.
+	testDB := "test_internal_" + sqltest.SQLTestName(t)
+	if _, err := conn.Exec(ctx, fmt.Sprintf(`CREATE DATABASE "%s";`, testDB)); err != nil {
+		t.Fatalf("cannot create database: %v", err)
+	}
+	migration := sqltest.New(t, sqltest.Options{
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		ForceReset:              true,
+	})
+	pool := migration.Setup(ctx, "")
+	var n int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+		t.Errorf("cannot count posts: %v", err)
+	}
+}
+
+var checkExistingTemporaryDB = flag.Bool("check_existing_temporary_db", false, "if true, ExistingTemporaryDB should fail.")
+
+func TestExistingTemporaryDB(t *testing.T) {
+	t.Parallel()
+	if *checkExistingTemporaryDB {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{Path: "example/testdata/migrations"})
+		migration.Setup(ctx, "")
+		return
+	}
+
+	// Prepare clean environment.
+	ctx := context.Background()
+	conn, err := pgx.Connect(context.Background(), "")
+	if err != nil {
+		t.Fatalf("connection error: %v", err)
+	}
+
+	testDB := sqltest.SQLTestName(t)
+	_, err = conn.Exec(ctx, fmt.Sprintf(`CREATE DATABASE "%s";`, testDB))
+	if err != nil {
+		t.Fatalf("cannot create database: %v", err)
+	}
+	defer func() {
+		conn.Exec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, testDB))
+	}()
+
+	args := []string{
+		"-test.v",
+		"-test.run=TestExistingTemporaryDB",
+		"-check_existing_temporary_db",
+	}
+	if *force {
+		args = append(args, "-force")
+	}
+	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	for _, want := range []string{
+		`cannot create database: database "testexistingtemporarydb" already exists, and wasn't created by this test`,
+		`ERROR: database "testexistingtemporarydb" already exists`,
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("got %q, wanted %q", out, want)
+		}
+	}
+}
+
+func TestRunDownMigrations(t *testing.T) {
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:             *force,
+		Path:              "example/testdata/updown-migrations",
+		UseExisting:       true,
+		SkipTeardown:      true,
+		RunDownMigrations: true,
+	})
+	conn := migration.Setup(ctx, "")
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass('posts') IS NOT NULL").Scan(&exists); err != nil {
+		t.Fatalf("cannot check table: %v", err)
+	}
+	if !exists {
+		t.Error("posts table should exist after migration")
+	}
+	migration.Teardown(ctx)
+
+	other, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("connection error: %v", err)
+	}
+	defer other.Close(ctx)
+	if err := other.QueryRow(ctx, "SELECT to_regclass('media') IS NOT NULL OR to_regclass('posts') IS NOT NULL").Scan(&exists); err != nil {
+		t.Fatalf("cannot check table: %v", err)
+	}
+	if exists {
+		t.Error("tables should be dropped by down migrations")
+	}
+}
+
+var checkMissingDownMigration = flag.Bool("check_missing_down_migration", false, "if true, TestMissingDownMigration should fail.")
+
+func TestMissingDownMigration(t *testing.T) {
+	t.Parallel()
+	if *checkMissingDownMigration {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   *force,
+			Path:                    "testdata/missing-down",
+			TemporaryDatabasePrefix: "test_internal_",
+			RunDownMigrations:       true,
+		})
+		migration.Setup(ctx, "")
+		return
+	}
+
+	args := []string{
+		"-test.v",
+		"-test.run=TestMissingDownMigration",
+		"-check_missing_down_migration",
+	}
+	if *force {
+		args = append(args, "-force")
+	}
+	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	if want := []byte("cannot load migrations: missing down migration for version 2 (002_posts.up.sql)"); !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+}
+
+func TestMigrationUninitialized(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		want := "migration must be initialized with sqltest.New()"
+		if r := recover(); r == nil || r != want {
+			t.Errorf("wanted panic %q, got %v instead", want, r)
+		}
+	}()
+	m := &sqltest.Migration{}
+	m.Setup(context.Background(), "")
+}
+
+func TestDatabaseNameBeforeSetup(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		want := "cannot get database name: Setup must be called first"
+		if r := recover(); r == nil || r != want {
+			t.Errorf("wanted panic %q, got %v instead", want, r)
+		}
+	}()
+	m := sqltest.New(t, sqltest.Options{Path: "example/testdata/migrations"})
+	m.DatabaseName()
 }
 
-var checkMigrationInvalidPath = flag.Bool("check_migration_invalid_path", false, "if true, TestMigrationInvalidPath should fail.")
+func TestSQLTestName(t *testing.T) {
+	t.Parallel()
+	want := []string{
+		"testsqltestname_foo",
+		"testsqltestname_foo_bar",
+	}
+	var got []string
+	t.Run("foo", func(t *testing.T) {
+		got = append(got, sqltest.SQLTestName(t))
+		t.Run("bar", func(t *testing.T) {
+			got = append(got, sqltest.SQLTestName(t))
+		})
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
 
-func TestMigrationInvalidPath(t *testing.T) {
-	if *checkMigrationInvalidPath {
+func TestNameFunc(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   true,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		NameFunc: func(t testing.TB) string {
+			return "custom_name"
+		},
+	})
+	migration.Setup(context.Background(), "")
+	if got, want := migration.DatabaseName(), "test_internal_custom_name"; got != want {
+		t.Errorf("expected database name to be %q, got %q instead", want, got)
+	}
+}
+
+var checkNameCollision = flag.Bool("check_name_collision", false, "if true, TestNameCollision should fail.")
+
+func TestNameCollision(t *testing.T) {
+	t.Parallel()
+	if *checkNameCollision {
 		ctx := context.Background()
-		migration := sqltest.New(t, sqltest.Options{Force: *force, Path: "testdata/invalid", UseExisting: true})
-		migration.Setup(ctx, "")
+		opts := sqltest.Options{
+			Force:                   true,
+			Path:                    "example/testdata/migrations",
+			TemporaryDatabasePrefix: "test_internal_",
+			NameFunc: func(t testing.TB) string {
+				return "collision"
+			},
+		}
+		sqltest.New(t, opts).Setup(ctx, "")
+		sqltest.New(t, opts).Setup(ctx, "")
 		return
 	}
 
-	args := []string{
-		"-test.v",
-		"-test.run=TestMigrationInvalidPath",
-		"-check_migration_invalid_path",
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestNameCollision", "-check_name_collision").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
 	}
-	if *force {
-		args = append(args, "-force")
+	want := []byte(`cannot use "test_internal_collision" for TestNameCollision, as it's already used by TestNameCollision`)
+	if !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
 	}
-	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+}
+
+// recordLogger records the messages logged by sqltest.
+type recordLogger struct {
+	messages []string
+}
+
+func (l *recordLogger) Logf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	logger := &recordLogger{}
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   true,
+		Path:                    "example/testdata/migrations",
+		TemporaryDatabasePrefix: "test_internal_",
+		Logger:                  logger,
+	})
+	migration.Setup(context.Background(), "")
+	want := []string{
+		"setup PostgreSQL database",
+		"executing 001_media.sql up",
+		"executing 002_settings.sql up",
+		"executing 003_posts.sql up",
+	}
+	var got []string
+	for _, msg := range logger.messages {
+		if msg == "setup PostgreSQL database" || strings.HasPrefix(msg, "executing") && strings.HasSuffix(msg, " up") {
+			got = append(got, msg)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected messages to be %q, got %q instead", want, got)
+	}
+}
+
+var checkFailedStatement = flag.Bool("check_failed_statement", false, "if true, TestFailedStatement should fail.")
+
+func TestFailedStatement(t *testing.T) {
+	t.Parallel()
+	if *checkFailedStatement {
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   true,
+			Path:                    "testdata/invalid-sql",
+			TemporaryDatabasePrefix: "test_internal_",
+		})
+		migration.Setup(context.Background(), "")
+		return
+	}
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestFailedStatement", "-check_failed_statement").CombinedOutput()
 	if err == nil {
 		t.Error("expected command to fail")
 	}
-	if want := []byte("cannot load migrations: open testdata/invalid: no such file or directory"); !bytes.Contains(out, want) {
-		t.Errorf("got %q, wanted %q", out, want)
+	for _, want := range []string{
+		"executing 001_tags.sql up",
+		"migration failed at line 11, statement 3 of 3:",
+		"CREATE INDEX tags_name ON tags(title);",
+		`cannot apply migrations: ERROR: column "title" does not exist`,
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("got %q, wanted %q", out, want)
+		}
 	}
 }
 
-// Check what happens if there is a dirty migration.
-var checkMigrationDirty = flag.Bool("check_migration_dirty", false, "if true, TestMigrationDirty should fail.")
+var checkKeepOnFailure = flag.Bool("check_keep_on_failure", false, "if true, TestKeepOnFailure should fail.")
 
-func TestMigrationDirty(t *testing.T) {
-	if *checkMigrationDirty {
+func TestKeepOnFailure(t *testing.T) {
+	t.Parallel()
+	if *checkKeepOnFailure {
 		ctx := context.Background()
-		migration := sqltest.New(t, sqltest.Options{Path: "example/testdata/migrations", UseExisting: true})
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   true,
+			Path:                    "example/testdata/migrations",
+			TemporaryDatabasePrefix: "test_internal_",
+			KeepOnFailure:           true,
+		})
 		migration.Setup(ctx, "")
+		t.Error("failing on purpose")
 		return
 	}
 
-	// Prepare clean environment.
 	ctx := context.Background()
-	migration := sqltest.New(t, sqltest.Options{Force: *force, Path: "example/testdata/migrations", UseExisting: true})
-	conn := migration.Setup(ctx, "")
-
-	// Check if the migration version matches with the number of migration files.
-	entries, err := ioutil.ReadDir("example/testdata/migrations")
+	conn, err := pgx.Connect(ctx, "")
 	if err != nil {
-		t.Errorf("cannot read migrations dir: %v", err)
-	}
-	var migrations int
-	for _, f := range entries {
-		if strings.HasSuffix(f.Name(), ".sql") {
-			migrations++
-		}
+		t.Fatalf("connection error: %v", err)
 	}
+	defer conn.Close(ctx)
+	testDB := "test_internal_" + sqltest.SQLTestName(t)
+	defer func() {
+		conn.Exec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, testDB))
+	}()
 
-	// Let's update the schema_version to make it dirty, and verify we are unable to run the tests.
-	if _, err := conn.Exec(context.Background(), "UPDATE schema_version SET version = $1 WHERE version = $2", migrations+1, migrations); err != nil {
-		t.Errorf("cannot update migration version: %q", err)
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestKeepOnFailure", "-check_keep_on_failure").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	if want := []byte(fmt.Sprintf("keeping database %q", testDB)); !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", testDB).Scan(&exists); err != nil {
+		t.Fatalf("cannot check database: %v", err)
+	}
+	if !exists {
+		t.Errorf("expected database %q to be kept", testDB)
 	}
+}
 
-	args := []string{
-		"-test.v",
-		"-test.run=TestMigrationDirty",
-		"-check_migration_dirty",
+var checkSingleTransaction = flag.Bool("check_single_transaction", false, "if true, TestSingleTransaction should fail.")
+
+func TestSingleTransaction(t *testing.T) {
+	t.Parallel()
+	const testDB = "test_internal_single_transaction"
+	if *checkSingleTransaction {
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   true,
+			Path:                    "testdata/single-tx",
+			TemporaryDatabasePrefix: "test_internal_",
+			NameFunc: func(t testing.TB) string {
+				return "single_transaction"
+			},
+			SingleTransaction: true,
+			KeepOnFailure:     true,
+		})
+		migration.Setup(context.Background(), "")
+		return
 	}
-	if *force {
-		args = append(args, "-force")
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "")
+	if err != nil {
+		t.Fatalf("connection error: %v", err)
 	}
-	out, err := exec.Command(os.Args[0], args...).CombinedOutput()
+	defer conn.Close(ctx)
+	defer func() {
+		conn.Exec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, testDB))
+	}()
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestSingleTransaction", "-check_single_transaction").CombinedOutput()
 	if err == nil {
 		t.Error("expected command to fail")
 	}
-	want := []byte(`database is dirty, please fix "schema_version" table manually or try -force`)
-	if !bytes.Contains(out, want) {
+	if want := []byte(`cannot apply migrations: ERROR: column "title" does not exist`); !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+	// The statement is found from the position of the error, as the first migration was rolled back too.
+	if want := []byte("migration failed at line 1, statement 1 of 1:"); !bytes.Contains(out, want) {
 		t.Errorf("got %q, wanted %q", out, want)
 	}
 
-	// Manually reset migration version to be able to test again to after the first 'legit' migration:
-	if _, err := conn.Exec(context.Background(), "UPDATE schema_version SET version = $1", migrations); err != nil {
-		t.Errorf("cannot update migration version: %q", err)
+	// The first migration must be rolled back with the second one.
+	config := conn.Config().Copy()
+	config.Database = testDB
+	kept, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("cannot connect to kept database: %v", err)
+	}
+	defer kept.Close(ctx)
+	var exists bool
+	if err := kept.QueryRow(ctx, "SELECT to_regclass('tags') IS NOT NULL").Scan(&exists); err != nil {
+		t.Fatalf("cannot check table: %v", err)
+	}
+	if exists {
+		t.Error("tags table should not exist after a failed migration")
+	}
+	var version int32
+	if err := kept.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&version); err != nil {
+		t.Fatalf("cannot get schema version: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("expected schema version to be 0, got %d instead", version)
 	}
 }
 
-var checkExistingTemporaryDB = flag.Bool("check_existing_temporary_db", false, "if true, ExistingTemporaryDB should fail.")
+var checkSingleTransactionConcurrently = flag.Bool("check_single_transaction_concurrently", false, "if true, TestSingleTransactionConcurrently should fail.")
 
-func TestExistingTemporaryDB(t *testing.T) {
+func TestSingleTransactionConcurrently(t *testing.T) {
 	t.Parallel()
-	if *checkExistingTemporaryDB {
-		ctx := context.Background()
-		migration := sqltest.New(t, sqltest.Options{Path: "example/testdata/migrations"})
+	if *checkSingleTransactionConcurrently {
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   true,
+			Path:                    "testdata/concurrently",
+			TemporaryDatabasePrefix: "test_internal_",
+			SingleTransaction:       true,
+		})
+		migration.Setup(context.Background(), "")
+		return
+	}
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestSingleTransactionConcurrently", "-check_single_transaction_concurrently").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	want := []byte("cannot apply migrations in a single transaction: 001_tags.sql uses CONCURRENTLY at line 6, statement 2")
+	if !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+}
+
+var checkSetupContext = flag.Bool("check_setup_context", false, "if true, TestSetupContext should fail.")
+
+func TestSetupContext(t *testing.T) {
+	t.Parallel()
+	if *checkSetupContext {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   true,
+			Path:                    "testdata/slow",
+			TemporaryDatabasePrefix: "test_internal_",
+		})
 		migration.Setup(ctx, "")
 		return
 	}
 
-	// Prepare clean environment.
+	start := time.Now()
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestSetupContext", "-check_setup_context").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("expected Setup to fail once the context deadline is exceeded, took %v instead", elapsed)
+	}
+	if want := []byte("cannot apply migrations: "); !bytes.Contains(out, want) {
+		t.Errorf("got %q, wanted %q", out, want)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	t.Parallel()
 	ctx := context.Background()
-	conn, err := pgx.Connect(context.Background(), "")
-	if err != nil {
-		t.Fatalf("connection error: %v", err)
+	migration := sqltest.New(t, sqltest.Options{
+		Force:                   *force,
+		Paths:                   []string{"testdata/paths/base", "testdata/paths/service"},
+		TemporaryDatabasePrefix: "test_internal_",
+	})
+	conn := migration.Setup(ctx, "")
+	for _, table := range []string{"media", "settings", "posts"} {
+		var n int
+		if err := conn.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM "%s"`, table)).Scan(&n); err != nil {
+			t.Errorf("cannot count %s: %v", table, err)
+		}
 	}
+}
 
-	testDB := sqltest.SQLTestName(t)
-	_, err = conn.Exec(ctx, fmt.Sprintf(`CREATE DATABASE "%s";`, testDB))
-	if err != nil {
-		t.Fatalf("cannot create database: %v", err)
+var checkDuplicatePaths = flag.Bool("check_duplicate_paths", false, "if true, TestDuplicatePaths should fail.")
+
+func TestDuplicatePaths(t *testing.T) {
+	t.Parallel()
+	if *checkDuplicatePaths {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{
+			Force:                   *force,
+			Paths:                   []string{"testdata/paths/base", "testdata/paths/duplicate"},
+			TemporaryDatabasePrefix: "test_internal_",
+		})
+		migration.Setup(ctx, "")
+		return
 	}
-	defer func() {
-		conn.Exec(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s";`, testDB))
-	}()
 
 	args := []string{
 		"-test.v",
-		"-test.run=TestExistingTemporaryDB",
-		"-check_existing_temporary_db",
+		"-test.run=TestDuplicatePaths",
+		"-check_duplicate_paths",
 	}
 	if *force {
 		args = append(args, "-force")
@@ -183,38 +1641,122 @@ func TestExistingTemporaryDB(t *testing.T) {
 	if err == nil {
 		t.Error("expected command to fail")
 	}
-	want := []byte(`cannot create database: ERROR: database "testexistingtemporarydb" already exists`)
-	if !bytes.Contains(out, want) {
+	if want := []byte("cannot load migrations: duplicate migration version 2 in testdata/paths/base and testdata/paths/duplicate"); !bytes.Contains(out, want) {
 		t.Errorf("got %q, wanted %q", out, want)
 	}
 }
 
-func TestMigrationUninitialized(t *testing.T) {
+var checkConnectRetries = flag.Bool("check_connect_retries", false, "if true, TestConnectRetries should fail.")
+
+func TestConnectRetries(t *testing.T) {
 	t.Parallel()
-	defer func() {
-		want := "migration must be initialized with sqltest.New()"
-		if r := recover(); r == nil || r != want {
-			t.Errorf("wanted panic %q, got %v instead", want, r)
+	if *checkConnectRetries {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{
+			Path:           "example/testdata/migrations",
+			Host:           "127.0.0.1",
+			Port:           1, // Nothing should be listening on this port.
+			ConnectTimeout: 100 * time.Millisecond,
+			ConnectRetries: 2,
+		})
+		migration.Setup(ctx, "")
+		return
+	}
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestConnectRetries", "-check_connect_retries").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	for _, want := range []string{
+		"cannot connect to database (attempt 1 of 3)",
+		"cannot connect to database (attempt 2 of 3)",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("got %q, wanted %q", out, want)
 		}
-	}()
-	m := &sqltest.Migration{}
-	m.Setup(context.Background(), "")
+	}
+	if want := "attempt 3 of 3"; bytes.Contains(out, []byte(want)) {
+		t.Errorf("got %q, wanted last attempt not to be logged", out)
+	}
 }
 
-func TestSQLTestName(t *testing.T) {
-	t.Parallel()
-	want := []string{
-		"testsqltestname_foo",
-		"testsqltestname_foo_bar",
+var checkModifiedMigration = flag.Bool("check_modified_migration", false, "if true, TestModifiedMigration should fail.")
+
+func TestModifiedMigration(t *testing.T) {
+	if *checkModifiedMigration {
+		ctx := context.Background()
+		migration := sqltest.New(t, sqltest.Options{Force: true, Path: "example/testdata/migrations", UseExisting: true})
+		migration.Setup(ctx, "")
+		return
 	}
-	var got []string
-	t.Run("foo", func(t *testing.T) {
-		got = append(got, sqltest.SQLTestName(t))
-		t.Run("bar", func(t *testing.T) {
-			got = append(got, sqltest.SQLTestName(t))
-		})
+
+	ctx := context.Background()
+	migration := sqltest.New(t, sqltest.Options{Force: *force, Path: "example/testdata/migrations", UseExisting: true, SkipTeardown: true})
+	pool := migration.Setup(ctx, "")
+
+	// Pretend the migration file was modified after it was applied by changing its checksum.
+	if _, err := pool.Exec(ctx, "UPDATE schema_version_checksums SET checksum = 'edited' WHERE version = 2"); err != nil {
+		t.Fatalf("cannot update migration checksum: %v", err)
+	}
+	// The modification must be caught even after the migrations are undone.
+	migration.Teardown(ctx)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(ctx, "")
+		if err != nil {
+			t.Fatalf("connection error: %v", err)
+		}
+		defer conn.Close(ctx)
+		if _, err := conn.Exec(ctx, "DELETE FROM schema_version_checksums WHERE version = 2"); err != nil {
+			t.Errorf("cannot delete migration checksum: %v", err)
+		}
 	})
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("got %q, want %q", got, want)
+
+	out, err := exec.Command(os.Args[0], "-test.v", "-test.run=TestModifiedMigration", "-check_modified_migration").CombinedOutput()
+	if err == nil {
+		t.Error("expected command to fail")
+	}
+	want := []byte("cannot verify migrations: migration 2 (002_settings.sql) was modified after it was applied: checksum is ")
+	if !bytes.Contains(out, want) || !bytes.Contains(out, []byte("but edited was applied")) {
+		t.Errorf("got %q, wanted %q", out, want)
 	}
 }
+
+func TestPoolSize(t *testing.T) {
+	t.Parallel()
+	var (
+		mu        sync.Mutex
+		databases = map[string]struct{}{}
+	)
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			migration := sqltest.New(t, sqltest.Options{
+				Path:                    "example/testdata/migrations",
+				TemporaryDatabasePrefix: "test_internal_",
+				PoolSize:                2,
+			})
+			conn := migration.Setup(ctx, "")
+			mu.Lock()
+			databases[migration.DatabaseName()] = struct{}{}
+			mu.Unlock()
+
+			// Each test must see an empty database, even if it's reused.
+			if _, err := conn.Exec(ctx, "INSERT INTO posts (id, name, message) VALUES ('1', 'hello', 'Hello, world!')"); err != nil {
+				t.Errorf("cannot insert post: %v", err)
+			}
+			var n int
+			if err := conn.QueryRow(ctx, "SELECT count(*) FROM posts").Scan(&n); err != nil {
+				t.Errorf("cannot count posts: %v", err)
+			}
+			if n != 1 {
+				t.Errorf("got %d posts, wanted %d", n, 1)
+			}
+		})
+	}
+	t.Cleanup(func() {
+		if len(databases) > 2 {
+			t.Errorf("got %d databases, wanted at most %d", len(databases), 2)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package sqltest
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// statement of a migration file.
+type statement struct {
+	// SQL of the statement, including its semicolon if any.
+	SQL string
+
+	// Offset of the statement in the file, in bytes.
+	Offset int
+
+	// Line of the file where the statement starts, starting from 1.
+	Line int
+}
+
+// splitStatements of a migration file, which are separated by semicolons.
+//
+// Semicolons inside string constants, quoted identifiers, dollar-quoted strings such as
+// function bodies, and comments don't end a statement.
+// Statements containing only comments are skipped.
+//
+// Reference: https://www.postgresql.org/docs/current/sql-syntax-lexical.html
+func splitStatements(sql string) []statement {
+	var statements []statement
+	start := 0
+	add := func(end int) {
+		s := sql[start:end]
+		trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+		if containsSQL(strings.TrimSuffix(strings.TrimSpace(stripComments(trimmed)), ";")) {
+			offset := start + len(s) - len(trimmed)
+			statements = append(statements, statement{
+				SQL:    strings.TrimRightFunc(trimmed, unicode.IsSpace),
+				Offset: offset,
+				Line:   1 + strings.Count(sql[:offset], "\n"),
+			})
+		}
+		start = end
+	}
+	for i := 0; i < len(sql); {
+		switch c := sql[i]; {
+		case c == ';':
+			i++
+			add(i)
+		case c == '\'':
+			// A backslash escapes a quote only in escape string constants, such as E'it\'s'.
+			escape := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentifierByte(sql[i-2]))
+			i = skipQuoted(sql, i, '\'', escape)
+		case c == '"':
+			i = skipQuoted(sql, i, '"', false)
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			i = skipBlockComment(sql, i)
+		case c == '$' && (i == 0 || !isIdentifierByte(sql[i-1])):
+			if tag, ok := dollarQuoteTag(sql[i:]); ok {
+				if n := strings.Index(sql[i+len(tag):], tag); n >= 0 {
+					i += len(tag) + n + len(tag)
+				} else {
+					i = len(sql)
+				}
+				continue
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	add(len(sql))
+	return statements
+}
+
+// skipQuoted returns the position after the quoted string or identifier starting at i.
+// A quote is escaped by doubling it, or with a backslash if escape is set.
+func skipQuoted(sql string, i int, quote byte, escape bool) int {
+	for i++; i < len(sql); i++ {
+		switch {
+		case escape && sql[i] == '\\':
+			i++
+		case sql[i] == quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// skipBlockComment returns the position after the block comment starting at i.
+// Block comments can be nested in PostgreSQL.
+func skipBlockComment(sql string, i int) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// dollarQuoteTag returns the tag starting a dollar-quoted string, such as $$ or $body$.
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); {
+		if s[i] == '$' {
+			return s[:i+1], true
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		// The tag follows the same rules as an unquoted identifier, except it cannot contain a dollar sign.
+		if !(r == '_' || unicode.IsLetter(r) || (i > 1 && unicode.IsDigit(r))) {
+			return "", false
+		}
+		i += size
+	}
+	return "", false
+}
+
+// isIdentifierByte reports whether the byte can be part of an unquoted identifier or a number.
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// stripComments of a statement, so it can be checked for SQL.
+func stripComments(sql string) string {
+	var b strings.Builder
+	for i := 0; i < len(sql); {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipBlockComment(sql, i)
+		default:
+			b.WriteByte(sql[i])
+			i++
+		}
+	}
+	return b.String()
+}
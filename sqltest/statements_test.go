@@ -0,0 +1,81 @@
+package sqltest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		sql  string
+		want []statement
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc: "comments only",
+			sql:  "-- nothing to see here;\n/* or; here */\n",
+		},
+		{
+			desc: "statements",
+			sql:  "CREATE TABLE a (id int);\n\nCREATE TABLE b (id int);\nSELECT 1",
+			want: []statement{
+				{SQL: "CREATE TABLE a (id int);", Offset: 0, Line: 1},
+				{SQL: "CREATE TABLE b (id int);", Offset: 26, Line: 3},
+				{SQL: "SELECT 1", Offset: 51, Line: 4},
+			},
+		},
+		{
+			desc: "strings",
+			sql:  `INSERT INTO a VALUES ('it''s; fine', E'it\'s; fine', "a;b");SELECT 2;`,
+			want: []statement{
+				{SQL: `INSERT INTO a VALUES ('it''s; fine', E'it\'s; fine', "a;b");`, Offset: 0, Line: 1},
+				{SQL: "SELECT 2;", Offset: 60, Line: 1},
+			},
+		},
+		{
+			desc: "backslash in standard string",
+			sql:  `SELECT 'C:\';SELECT 3;`,
+			want: []statement{
+				{SQL: `SELECT 'C:\';`, Offset: 0, Line: 1},
+				{SQL: "SELECT 3;", Offset: 13, Line: 1},
+			},
+		},
+		{
+			desc: "dollar quoted",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\nDO $body$ BEGIN PERFORM 1; END $body$;\nSELECT $1;",
+			want: []statement{
+				{SQL: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;", Offset: 0, Line: 1},
+				{SQL: "DO $body$ BEGIN PERFORM 1; END $body$;", Offset: 65, Line: 2},
+				{SQL: "SELECT $1;", Offset: 104, Line: 3},
+			},
+		},
+		{
+			desc: "comments",
+			sql:  "-- first; statement\nSELECT 1; /* nested /* comment; */ still; comment */ SELECT 2;\n-- trailing comment;",
+			want: []statement{
+				{SQL: "-- first; statement\nSELECT 1;", Offset: 0, Line: 1},
+				{SQL: "/* nested /* comment; */ still; comment */ SELECT 2;", Offset: 30, Line: 2},
+			},
+		},
+		{
+			desc: "empty statements",
+			sql:  "SELECT 1;;\n;",
+			want: []statement{
+				{SQL: "SELECT 1;", Offset: 0, Line: 1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := splitStatements(tc.sql); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected statements to be %#v, got %#v instead", tc.want, got)
+			}
+		})
+	}
+}
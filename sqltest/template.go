@@ -0,0 +1,165 @@
+package sqltest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// templates created by this process, keyed by name.
+var templates = struct {
+	mu sync.Mutex // guards following
+	m  map[string]error
+}{
+	m: map[string]error{},
+}
+
+// ensureTemplate returns the name of the template database with the migrations applied,
+// creating it the first time it's used by the process.
+func (m *Migration) ensureTemplate(ctx context.Context, connString string) (string, error) {
+	hash, err := m.migrationsHash()
+	if err != nil {
+		return "", fmt.Errorf("cannot hash migrations: %w", err)
+	}
+	name := DatabasePrefix + "_template_" + hash[:16]
+
+	templates.mu.Lock()
+	defer templates.mu.Unlock()
+	err, ok := templates.m[name]
+	if !ok {
+		// Other processes, such as the test binaries of other packages, might create it at the same time.
+		err = m.withAdvisoryLock(ctx, name, func() error {
+			return m.createTemplate(ctx, connString, name)
+		})
+		// Try again on the next test if the context of this one was canceled.
+		if ctx.Err() == nil {
+			templates.m[name] = err
+		}
+	}
+	return name, err
+}
+
+// createTemplate database, unless it was already created by another process with the same migrations.
+// An existing database that isn't a template yet is replaced, as it was left behind by an interrupted test run,
+// and so is an existing template if Force or ForceReset is set.
+func (m *Migration) createTemplate(ctx context.Context, connString, name string) error {
+	var isTemplate bool
+	err := m.adminQueryRow(ctx, &isTemplate, "SELECT datistemplate FROM pg_database WHERE datname = $1", name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	// The database is only marked as a template once it's migrated.
+	if isTemplate && !m.Options.Force && !m.Options.ForceReset {
+		m.logf("using existing template database %q", name)
+		return nil
+	}
+	m.logf("creating template database %q", name)
+	if exists {
+		// A template database cannot be dropped.
+		if err := m.adminExec(ctx, fmt.Sprintf(`ALTER DATABASE "%s" IS_TEMPLATE false;`, name)); err != nil {
+			return err
+		}
+		if err := m.adminExec(ctx, fmt.Sprintf(`DROP DATABASE "%s";`, name)); err != nil {
+			return err
+		}
+	}
+	if err := m.adminExec(ctx, fmt.Sprintf(`CREATE DATABASE "%s"%s;`, name, m.Options.databaseSettings())); err != nil {
+		return err
+	}
+
+	config, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return err
+	}
+	config.Database = name
+	if m.Options.ConnectTimeout > 0 {
+		config.ConnectTimeout = m.Options.ConnectTimeout
+	}
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+	if err := m.migrateTemplate(ctx, conn); err != nil {
+		conn.Close(ctx)
+		return err
+	}
+	// No other connections to the template database are allowed when creating a database from it.
+	if err := conn.Close(ctx); err != nil {
+		return err
+	}
+	return m.adminExec(ctx, fmt.Sprintf(`ALTER DATABASE "%s" IS_TEMPLATE true;`, name))
+}
+
+// withAdvisoryLock calls fn while holding a PostgreSQL advisory lock keyed by name on the admin connection,
+// so that processes sharing the server don't run it concurrently.
+func (m *Migration) withAdvisoryLock(ctx context.Context, name string, fn func() error) error {
+	h := sha256.Sum256([]byte(name))
+	key := int64(binary.BigEndian.Uint64(h[:8]))
+	if err := m.adminExec(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return fmt.Errorf("cannot acquire advisory lock: %w", err)
+	}
+	// Unlock even if the context was canceled, as the lock is held until the end of the session otherwise.
+	defer m.adminExec(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	return fn()
+}
+
+// migrateTemplate applies the migrations to the template database.
+func (m *Migration) migrateTemplate(ctx context.Context, conn *pgx.Conn) error {
+	if err := m.beforeMigrate(ctx, conn); err != nil {
+		return err
+	}
+	if m.Options.Runner != nil {
+		return m.runMigrations(ctx, conn)
+	}
+	migrator, err := m.newMigrator(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("cannot run migration: %w", err)
+	}
+	if err := m.loadMigrations(migrator); err != nil {
+		return fmt.Errorf("cannot load migrations: %w", err)
+	}
+	return m.applyMigrations(ctx, conn, migrator, false)
+}
+
+// migrationsHash returns the SHA-256 hash of the files in the migration path,
+// and of the options changing the migrated database, such as the version table and template data,
+// as a database migrated with different ones cannot be reused.
+func (m *Migration) migrationsHash() (string, error) {
+	fsys, dir := m.migrationsFS()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", m.versionTable(), m.Options.Template, m.Options.Encoding, m.Options.Locale)
+	data, err := templateData(m.Options.TemplateData)
+	if err != nil {
+		return "", err
+	}
+	if data != nil {
+		writeValue(h, reflect.ValueOf(data))
+		h.Write([]byte{0})
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", e.Name(), len(b))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,31 @@
+package sqltest
+
+import "testing"
+
+func TestMigrationsHashTemplateData(t *testing.T) {
+	type data struct {
+		Tablespace *string
+	}
+	hash := func(v interface{}) string {
+		t.Helper()
+		m := &Migration{Options: Options{Path: "testdata/template-data/tern", TemplateData: v}}
+		h, err := m.migrationsHash()
+		if err != nil {
+			t.Fatalf("cannot hash migrations: %v", err)
+		}
+		return h
+	}
+	a, b, c := "fast", "fast", "slow"
+	if ha, hb := hash(data{&a}), hash(&data{&b}); ha != hb {
+		t.Errorf("expected hashes of equal values behind different pointers to match, got %q and %q instead", ha, hb)
+	}
+	if ha, hc := hash(data{&a}), hash(data{&c}); ha == hc {
+		t.Errorf("expected hashes of different values to differ, got %q for both", ha)
+	}
+	if ha, hm := hash(data{&a}), hash(map[string]interface{}{"Tablespace": &b}); ha != hm {
+		t.Errorf("expected hash of a struct to match the hash of the equivalent map, got %q and %q instead", ha, hm)
+	}
+	if hn, hd := hash(nil), hash(data{&a}); hn == hd {
+		t.Errorf("expected hash without template data to differ, got %q for both", hn)
+	}
+}
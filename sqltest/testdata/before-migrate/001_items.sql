@@ -0,0 +1,7 @@
+-- The app schema is created by BeforeMigrate.
+CREATE TABLE app.items (
+	id text PRIMARY KEY
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS app.items;
@@ -0,0 +1,9 @@
+CREATE TABLE tags (
+	id text PRIMARY KEY,
+	name text NOT NULL
+);
+
+CREATE INDEX CONCURRENTLY tags_name ON tags(name);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,4 @@
+-- This migration doesn't do anything.
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS nothing;
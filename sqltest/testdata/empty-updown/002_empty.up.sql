@@ -0,0 +1 @@
+-- This migration doesn't do anything.
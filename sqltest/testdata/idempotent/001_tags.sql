@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id text PRIMARY KEY,
+	name text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tags_name ON tags(name);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS tags;
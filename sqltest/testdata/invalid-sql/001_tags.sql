@@ -0,0 +1,15 @@
+CREATE TABLE tags (
+	id text PRIMARY KEY,
+	name text NOT NULL
+);
+
+-- The semicolons of the function body don't end the statement.
+CREATE FUNCTION tag_label(t tags) RETURNS text AS $$
+	SELECT t.name || '; ' || t.id;
+$$ LANGUAGE sql;
+
+CREATE INDEX tags_name ON tags(title);
+
+---- create above / drop below ----
+DROP FUNCTION IF EXISTS tag_label;
+DROP TABLE IF EXISTS tags;
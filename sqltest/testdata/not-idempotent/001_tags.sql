@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id text PRIMARY KEY,
+	name text NOT NULL
+);
+
+ALTER TABLE tags ADD COLUMN color text;
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS tags;
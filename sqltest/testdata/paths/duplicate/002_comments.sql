@@ -0,0 +1,7 @@
+CREATE TABLE comments (
+	id text PRIMARY KEY,
+	message text NOT NULL
+);
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1 @@
+INSERT INTO serials (id, name) VALUES (1, 'one'), (2, 'two'), (7, 'seven');
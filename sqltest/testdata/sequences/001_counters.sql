@@ -0,0 +1,13 @@
+CREATE TABLE serials (
+	id serial PRIMARY KEY,
+	name text NOT NULL
+);
+
+CREATE TABLE identities (
+	id bigint GENERATED BY DEFAULT AS IDENTITY (START WITH 100) PRIMARY KEY,
+	name text NOT NULL
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS serials;
@@ -0,0 +1,7 @@
+CREATE TABLE tags (
+	id text PRIMARY KEY,
+	name text NOT NULL
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS tags;
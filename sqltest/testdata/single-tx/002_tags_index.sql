@@ -0,0 +1,4 @@
+CREATE INDEX tags_name ON tags(title);
+
+---- create above / drop below ----
+DROP INDEX IF EXISTS tags_name;
@@ -0,0 +1,5 @@
+-- A migration that hangs, to check Setup honors the deadline of its context.
+SELECT pg_sleep(60);
+
+---- create above / drop below ----
+SELECT 1;
@@ -0,0 +1,7 @@
+CREATE TABLE {{.Table}} (
+	id bigint PRIMARY KEY,
+	name text NOT NULL
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS {{.Table}};
@@ -0,0 +1,4 @@
+CREATE TABLE {{.Table}} (
+	id bigint PRIMARY KEY,
+	name text NOT NULL
+);
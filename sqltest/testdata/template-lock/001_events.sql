@@ -0,0 +1,7 @@
+CREATE TABLE events (
+	id bigint PRIMARY KEY,
+	name text NOT NULL
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,83 @@
+package sqltest
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jackc/tern/migrate"
+)
+
+// Validate the migration files of the options without connecting to a database,
+// such as in a CI check that runs before the integration tests.
+//
+// It checks the files are named and ordered as required by tern, or golang-migrate style
+// up and down migrations are paired, and that every up migration contains SQL.
+// The SQL itself isn't parsed, so Setup can still fail to apply valid migrations.
+// The first problem found is returned, with the name of the file and the reason.
+//
+// Migrations for a custom Runner aren't validated.
+func Validate(o Options) error {
+	if o.Runner != nil {
+		return nil
+	}
+	m := &Migration{Options: o}
+	if len(o.Paths) > 0 {
+		merged, err := m.mergeMigrations()
+		if err != nil {
+			return err
+		}
+		m.merged = merged
+	}
+	fsys, dir := m.migrationsFS()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && upDownPattern.MatchString(e.Name()) {
+			migrator := &migrate.Migrator{}
+			if err := m.loadUpDownMigrations(migrator, fsys, dir, entries); err != nil {
+				return err
+			}
+			for _, mig := range migrator.Migrations {
+				if !containsSQL(mig.UpSQL) {
+					return fmt.Errorf("%s: %w", mig.Name, migrate.ErrNoFwMigration)
+				}
+			}
+			return nil
+		}
+	}
+
+	paths, err := migrate.FindMigrationsEx(dir, migratorFS{fsys: fsys})
+	if err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return migrate.NoMigrationsFoundError{Path: dir}
+	}
+	for _, p := range paths {
+		b, err := fs.ReadFile(fsys, path.Clean(p))
+		if err != nil {
+			return err
+		}
+		up := strings.SplitN(string(b), "---- create above / drop below ----", 2)[0]
+		if !containsSQL(up) {
+			return fmt.Errorf("%s: %w", path.Base(p), migrate.ErrNoFwMigration)
+		}
+	}
+	return nil
+}
+
+// containsSQL reports whether the SQL contains something other than blank lines and comments,
+// as checked by tern for up migrations.
+func containsSQL(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
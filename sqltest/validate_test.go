@@ -0,0 +1,72 @@
+package sqltest_test
+
+import (
+	"testing"
+
+	"github.com/partounian/pgtools/sqltest"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		opts sqltest.Options
+		want string
+	}{
+		{
+			desc: "tern",
+			opts: sqltest.Options{Path: "example/testdata/migrations"},
+		},
+		{
+			desc: "updown",
+			opts: sqltest.Options{Path: "example/testdata/updown-migrations", RunDownMigrations: true},
+		},
+		{
+			desc: "paths",
+			opts: sqltest.Options{Paths: []string{"testdata/paths/base", "testdata/paths/service"}},
+		},
+		{
+			desc: "invalid path",
+			opts: sqltest.Options{Path: "testdata/invalid"},
+			want: "open testdata/invalid: no such file or directory",
+		},
+		{
+			desc: "missing down",
+			opts: sqltest.Options{Path: "testdata/missing-down", RunDownMigrations: true},
+			want: "missing down migration for version 2 (002_posts.up.sql)",
+		},
+		{
+			desc: "duplicate paths",
+			opts: sqltest.Options{Paths: []string{"testdata/paths/base", "testdata/paths/duplicate"}},
+			want: "duplicate migration version 2 in testdata/paths/base and testdata/paths/duplicate",
+		},
+		{
+			desc: "gap",
+			opts: sqltest.Options{Path: "testdata/gap"},
+			want: "testdata/gap: Missing migration 2",
+		},
+		{
+			desc: "empty up",
+			opts: sqltest.Options{Path: "testdata/empty-up"},
+			want: "002_empty.sql: no sql in forward migration step",
+		},
+		{
+			desc: "empty updown",
+			opts: sqltest.Options{Path: "testdata/empty-updown"},
+			want: "002_empty.up.sql: no sql in forward migration step",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			err := sqltest.Validate(tc.opts)
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.want != "" && (err == nil || err.Error() != tc.want):
+				t.Errorf("expected error to be %q, got %v instead", tc.want, err)
+			}
+		})
+	}
+}
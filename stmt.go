@@ -0,0 +1,20 @@
+package pgtools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// StmtName returns a name for a prepared statement using the columns of v, such as:
+//
+//	user_by_id_5e1d4c6a0bd4f3b2
+//
+// The name is the prefix followed by a hash of the expression returned by Wildcard,
+// so it's stable across process restarts while the columns of the struct don't change,
+// and changes when they do, avoiding the use of a stale prepared statement.
+//
+// Use a different prefix for each query using the same type.
+func StmtName(prefix string, v interface{}) string {
+	h := sha256.Sum256([]byte(Wildcard(v)))
+	return prefix + "_" + hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,42 @@
+package pgtools_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func TestStmtName(t *testing.T) {
+	t.Parallel()
+	type v1 struct {
+		ID   string
+		Name string
+	}
+	type v2 struct {
+		ID   string
+		Name string
+		Age  int
+	}
+	type renamed struct {
+		Key  string `db:"id"`
+		Name string
+	}
+
+	name := pgtools.StmtName("user_by_id", v1{})
+	if ok := regexp.MustCompile(`\Auser_by_id_[0-9a-f]{16}\z`).MatchString(name); !ok {
+		t.Errorf("unexpected statement name format: %q", name)
+	}
+	if got := pgtools.StmtName("user_by_id", &v1{}); got != name {
+		t.Errorf("expected statement name of pointer to be %q, got %q instead", name, got)
+	}
+	if got := pgtools.StmtName("user_by_id", renamed{}); got != name {
+		t.Errorf("expected statement name of type with the same columns to be %q, got %q instead", name, got)
+	}
+	if got := pgtools.StmtName("user_by_id", v2{}); got == name {
+		t.Errorf("expected statement name to change with the columns, got %q for both", got)
+	}
+	if got := pgtools.StmtName("user_by_email", v1{}); got == name {
+		t.Errorf("expected statement name to change with the prefix, got %q for both", got)
+	}
+}
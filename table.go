@@ -0,0 +1,27 @@
+package pgtools
+
+import "reflect"
+
+// Tabler is implemented by a struct that knows the name of its table, such as:
+//
+//	func (User) TableName() string { return "users" }
+//
+// Select and Insert use it when they're called with an empty table name.
+// The method is called on the zero value of the struct, so it must not depend on the value.
+type Tabler interface {
+	TableName() string
+}
+
+// TableOf returns the table name of v if it implements Tabler, with a value or a pointer receiver,
+// or an empty string otherwise.
+func TableOf(v interface{}) string {
+	rv := typeOf(v)
+	if rv == nil {
+		return ""
+	}
+	// Use a pointer to a zero value, so a nil pointer or a pointer receiver work too.
+	if t, ok := reflect.New(rv).Interface().(Tabler); ok {
+		return t.TableName()
+	}
+	return ""
+}
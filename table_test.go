@@ -0,0 +1,116 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+type account struct {
+	ID    int `db:"id,readonly"`
+	Email string
+}
+
+func (account) TableName() string { return "accounts" }
+
+type auditLog struct {
+	Message string
+}
+
+func (*auditLog) TableName() string { return "audit.logs" }
+
+func ExampleTableOf() {
+	fmt.Println(pgtools.TableOf(account{}))
+	fmt.Println(pgtools.Select("", account{}))
+	// Output:
+	// accounts
+	// SELECT "id","email" FROM "accounts"
+}
+
+func TestTableOf(t *testing.T) {
+	t.Parallel()
+	var nilPointer *auditLog
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want string
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    mock{},
+			desc: "none",
+		},
+		{
+			v:    account{},
+			desc: "value",
+			want: "accounts",
+		},
+		{
+			v:    &account{},
+			desc: "pointer",
+			want: "accounts",
+		},
+		{
+			v:    auditLog{},
+			desc: "pointer receiver",
+			want: "audit.logs",
+		},
+		{
+			v:    nilPointer,
+			desc: "nil pointer",
+			want: "audit.logs",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.TableOf(tc.v); got != tc.want {
+				t.Errorf("expected table to be %q, got %q instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTableOfHelpers(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		desc string
+		got  string
+		want string
+	}{
+		{
+			desc: "Select",
+			got:  pgtools.Select("", &auditLog{}),
+			want: `SELECT "message" FROM "audit"."logs"`,
+		},
+		{
+			desc: "Select explicit",
+			got:  pgtools.Select("users", account{}),
+			want: `SELECT "id","email" FROM "users"`,
+		},
+		{
+			desc: "Insert",
+			got:  pgtools.Insert("", account{}),
+			want: `INSERT INTO "accounts" ("email") VALUES ($1)`,
+		},
+		{
+			desc: "NewQuery",
+			got:  pgtools.NewQuery(account{}).Where("id = $1").Build(),
+			want: `SELECT "id","email" FROM "accounts" WHERE id = $1`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if tc.got != tc.want {
+				t.Errorf("expected statement to be %v, got %v instead", tc.want, tc.got)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package pgtools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// UpdateSet returns the assignment list for an UPDATE statement, such as:
+//
+//	"name"=$1,"email"=$2
+//
+// Placeholders are numbered starting from startIndex, so you can compose it with
+// a WHERE clause using the following placeholders:
+//
+//	args := pgtools.Args(u)
+//	sql := `UPDATE "user" SET ` + pgtools.UpdateSet(u, 1) + ` WHERE id = $` + strconv.Itoa(len(args)+1)
+//
+// Columns are listed in the same order as Fields, and follow the same "db" tag rules,
+// except that readonly and generated columns are omitted, so the number of placeholders is len(Args(v)).
+// Like Wildcard, an empty string is returned when no columns can be found.
+func UpdateSet(v interface{}, startIndex int) string {
+	var b strings.Builder
+	for n, s := range writableFields(v) {
+		if n != 0 {
+			b.WriteString(`,`)
+		}
+		writeIdentifier(&b, s)
+		b.WriteString(`=$`)
+		b.WriteString(strconv.Itoa(startIndex + n))
+	}
+	return b.String()
+}
+
+// UpdateSetNamed returns the assignment list for an UPDATE statement using named parameters, such as:
+//
+//	"name"=@name,"email"=@email
+//
+// Use it with the values returned by NamedArgs, so you don't need to number placeholders,
+// as in the following example using pgx v5:
+//
+//	sql := `UPDATE "user" SET ` + pgtools.UpdateSetNamed(u) + ` WHERE id = @id`
+//	_, err := conn.Exec(ctx, sql, pgx.NamedArgs(pgtools.NamedArgs(u)))
+//
+// Columns are listed like UpdateSet, and named like WildcardNamed.
+func UpdateSetNamed(v interface{}) string {
+	var b strings.Builder
+	for n, s := range writableFields(v) {
+		if n != 0 {
+			b.WriteString(`,`)
+		}
+		writeIdentifier(&b, s)
+		b.WriteString(`=@`)
+		b.WriteString(namedArg(s))
+	}
+	return b.String()
+}
+
+// OnConflictUpdate returns an ON CONFLICT clause for an upsert, such as:
+//
+//	ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name","email"=EXCLUDED."email"
+//
+// The conflict target and readonly columns are not updated, and the other columns are updated in the
+// same order as Fields.
+// If there are no columns left to update, DO NOTHING is used instead.
+func OnConflictUpdate(v interface{}, conflictCols []string) string {
+	var b strings.Builder
+	b.WriteString(`ON CONFLICT (`)
+	b.WriteString(quoteColumns(conflictCols))
+	b.WriteString(`) DO `)
+	var n int
+	for _, s := range writableFields(v) {
+		if contains(conflictCols, s) {
+			continue
+		}
+		if n == 0 {
+			b.WriteString(`UPDATE SET `)
+		} else {
+			b.WriteString(`,`)
+		}
+		n++
+		writeIdentifier(&b, s)
+		b.WriteString(`=EXCLUDED.`)
+		writeIdentifier(&b, s)
+	}
+	if n == 0 {
+		b.WriteString(`NOTHING`)
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+func ExampleUpdateSet() {
+	sql := `UPDATE "user" SET ` + pgtools.UpdateSet(User{}, 2) + ` WHERE id = $1`
+	fmt.Println(sql)
+	// Output:
+	// UPDATE "user" SET "username"=$2,"full_name"=$3,"email"=$4,"id"=$5,"theme"=$6 WHERE id = $1
+}
+
+func TestUpdateSet(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v          interface{}
+		startIndex int
+		desc       string
+		want       string
+	}{
+		{
+			v:          emptyEmbed{},
+			startIndex: 1,
+			desc:       "empty",
+		},
+		{
+			v:          nil,
+			startIndex: 1,
+			desc:       "nil",
+		},
+		{
+			v: struct {
+				One int
+			}{},
+			startIndex: 1,
+			desc:       "single",
+			want:       `"one"=$1`,
+		},
+		{
+			v:          &mock{},
+			startIndex: 1,
+			desc:       "mock",
+			want:       `"automatic"=$1,"tagged"=$2,"one_two"=$3,"CamelCase"=$4`,
+		},
+		{
+			v:          &mock{},
+			startIndex: 3,
+			desc:       "offset",
+			want:       `"automatic"=$3,"tagged"=$4,"one_two"=$5,"CamelCase"=$6`,
+		},
+		{
+			v:          mockEmbed{},
+			startIndex: 1,
+			desc:       "embed",
+			want:       `"before"=$1,"automatic"=$2,"tagged"=$3,"one_two"=$4,"CamelCase"=$5,"after"=$6`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.UpdateSet(tc.v, tc.startIndex); tc.want != got {
+				t.Errorf("expected assignments to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func ExampleUpdateSetNamed() {
+	sql := `UPDATE "user" SET ` + pgtools.UpdateSetNamed(User{}) + ` WHERE id = @id`
+	fmt.Println(sql)
+	// Output:
+	// UPDATE "user" SET "username"=@username,"full_name"=@full_name,"email"=@email,"id"=@id,"theme"=@theme WHERE id = @id
+}
+
+func TestUpdateSetNamed(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want string
+	}{
+		{
+			v:    emptyEmbed{},
+			desc: "empty",
+		},
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    &mock{},
+			desc: "mock",
+			want: `"automatic"=@automatic,"tagged"=@tagged,"one_two"=@one_two,"CamelCase"=@CamelCase`,
+		},
+		{
+			v: struct {
+				ID      string `db:"id,readonly"`
+				Name    string `db:"name"`
+				Ignored string `db:"-"`
+				Address address
+			}{},
+			desc: "readonly and nested",
+			want: `"name"=@name,"address.street"=@address__street,"address.city"=@address__city,"address"=@address`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.UpdateSetNamed(tc.v); tc.want != got {
+				t.Errorf("expected assignments to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func ExampleOnConflictUpdate() {
+	sql := pgtools.Insert("user", User{}) + " " + pgtools.OnConflictUpdate(User{}, []string{"username"})
+	fmt.Println(sql)
+	// Output:
+	// INSERT INTO "user" ("username","full_name","email","id","theme") VALUES ($1, $2, $3, $4, $5) ON CONFLICT ("username") DO UPDATE SET "full_name"=EXCLUDED."full_name","email"=EXCLUDED."email","id"=EXCLUDED."id","theme"=EXCLUDED."theme"
+}
+
+func TestOnConflictUpdate(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v            interface{}
+		conflictCols []string
+		desc         string
+		want         string
+	}{
+		{
+			v:            &mock{},
+			conflictCols: []string{"tagged"},
+			desc:         "mock",
+			want:         `ON CONFLICT ("tagged") DO UPDATE SET "automatic"=EXCLUDED."automatic","one_two"=EXCLUDED."one_two","CamelCase"=EXCLUDED."CamelCase"`,
+		},
+		{
+			v:            &mock{},
+			conflictCols: []string{"automatic", "CamelCase"},
+			desc:         "composite",
+			want:         `ON CONFLICT ("automatic","CamelCase") DO UPDATE SET "tagged"=EXCLUDED."tagged","one_two"=EXCLUDED."one_two"`,
+		},
+		{
+			v:            &mock{},
+			conflictCols: []string{"automatic", "tagged", "one_two", "CamelCase"},
+			desc:         "nothing",
+			want:         `ON CONFLICT ("automatic","tagged","one_two","CamelCase") DO NOTHING`,
+		},
+		{
+			v:            numericMock{},
+			conflictCols: []string{"number"},
+			desc:         "single",
+			want:         `ON CONFLICT ("number") DO NOTHING`,
+		},
+		{
+			v:            emptyEmbed{},
+			conflictCols: []string{"id"},
+			desc:         "empty",
+			want:         `ON CONFLICT ("id") DO NOTHING`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pgtools.OnConflictUpdate(tc.v, tc.conflictCols); tc.want != got {
+				t.Errorf("expected clause to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pgtools
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/partounian/pgtools/internal/structref"
+)
+
+// WhereByPK returns the condition of a WHERE clause to look up a row by its primary key, such as:
+//
+//	"id"=$1
+//
+// Primary key columns are the ones tagged with the "pk" option, as in `db:"id,pk"`.
+// The columns of a composite key are joined with AND in the same order as Fields:
+//
+//	"team_id"=$1 AND "user_id"=$2
+//
+// Placeholders are numbered starting from startIndex, so you can compose it with other clauses,
+// such as UpdateSet:
+//
+//	args := pgtools.Args(u)
+//	sql := `UPDATE "user" SET ` + pgtools.UpdateSet(u, 1) + ` WHERE ` + pgtools.WhereByPK(u, len(args)+1)
+//
+// If no column is tagged with "pk", an empty string is returned.
+// See PrimaryKeys for the order of the columns of a composite key.
+func WhereByPK(v interface{}, startIndex int) string {
+	var b strings.Builder
+	for n, s := range typeInfoOf(typeOf(v), structref.DefaultTagKey).primaryKeys {
+		if n != 0 {
+			b.WriteString(` AND `)
+		}
+		writeIdentifier(&b, s)
+		b.WriteString(`=$`)
+		b.WriteString(strconv.Itoa(startIndex + n))
+	}
+	return b.String()
+}
+
+// PrimaryKeys returns the columns tagged with the "pk" option, as in `db:"id,pk"`,
+// such as to use as the conflict target of OnConflictUpdate:
+//
+//	sql := pgtools.Insert("membership", m) + " " + pgtools.OnConflictUpdate(m, pgtools.PrimaryKeys(m))
+//
+// The columns of a composite key are listed in the order of the struct fields, like Fields,
+// so reorder the fields to match the order of the columns of the primary key in the database
+// if it matters to you, such as for the index to be used.
+// If no column is tagged with "pk", nil is returned.
+// The returned slice is a copy, so it's safe to modify it.
+func PrimaryKeys(v interface{}) []string {
+	return copyColumns(typeInfoOf(typeOf(v), structref.DefaultTagKey).primaryKeys)
+}
@@ -0,0 +1,118 @@
+package pgtools_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/partounian/pgtools"
+)
+
+type membership struct {
+	TeamID string `db:"team_id,pk"`
+	UserID string `db:"user_id,pk"`
+	Role   string
+}
+
+func ExampleWhereByPK() {
+	type post struct {
+		ID    string `db:"id,pk"`
+		Title string
+	}
+	sql := `SELECT ` + pgtools.Wildcard(post{}) + ` FROM "post" WHERE ` + pgtools.WhereByPK(post{}, 1)
+	fmt.Println(sql)
+	// Output:
+	// SELECT "id","title" FROM "post" WHERE "id"=$1
+}
+
+func TestWhereByPK(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v          interface{}
+		startIndex int
+		desc       string
+		want       string
+	}{
+		{
+			v:          nil,
+			startIndex: 1,
+			desc:       "nil",
+		},
+		{
+			v:          &User{},
+			startIndex: 1,
+			desc:       "no primary key",
+		},
+		{
+			v: struct {
+				ID   string `db:"id,pk,readonly"`
+				Name string
+			}{},
+			startIndex: 1,
+			desc:       "single",
+			want:       `"id"=$1`,
+		},
+		{
+			v:          &membership{},
+			startIndex: 2,
+			desc:       "composite",
+			want:       `"team_id"=$2 AND "user_id"=$3`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := pgtools.WhereByPK(tc.v, tc.startIndex); tc.want != got {
+				t.Errorf("expected condition to be %v, got %v instead", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrimaryKeys(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		v    interface{}
+		desc string
+		want []string
+	}{
+		{
+			v:    nil,
+			desc: "nil",
+		},
+		{
+			v:    User{},
+			desc: "no primary key",
+		},
+		{
+			v: struct {
+				Name string
+				ID   string `db:"id,readonly,pk"`
+			}{},
+			desc: "single",
+			want: []string{"id"},
+		},
+		{
+			v:    &membership{},
+			desc: "composite",
+			want: []string{"team_id", "user_id"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			got := pgtools.PrimaryKeys(tc.v)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected primary keys to be %v, got %v instead", tc.want, got)
+			}
+			if got != nil {
+				got[0] = "modified"
+				if again := pgtools.PrimaryKeys(tc.v); !reflect.DeepEqual(again, tc.want) {
+					t.Errorf("expected primary keys to be a copy, got %v after modifying it", again)
+				}
+			}
+		})
+	}
+}